@@ -0,0 +1,120 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "sync"
+)
+
+// -adaptive-concurrency 时替代 downloadSem 的下载调度器 (AIMD):
+// 并发从 1 开始，低于慢启动阈值时每次成功加 1 (每轮约翻倍)，之后每轮加 1；
+// 下载失败时阈值和并发都减半，上限为 -concurrency-downloads
+type adaptiveLimiter struct {
+    mu     sync.Mutex
+    limit  float64 // 当前并发上限，整数部分生效
+    thresh float64
+    max    int
+    inUse  int
+    epoch  int           // 每次减半后递增，减半前开始的下载再失败时不重复减半
+    wake   chan struct{} // 名额或上限变化时关闭并替换，唤醒等待者
+}
+
+var downloadLimiter *adaptiveLimiter
+
+func newAdaptiveLimiter(max int) *adaptiveLimiter {
+    return &adaptiveLimiter{limit: 1, thresh: float64(max), max: max, wake: make(chan struct{})}
+}
+
+// 一个下载名额: 每次尝试后用 report 反馈结果，结束时 release
+type downloadSlot struct {
+    limiter *adaptiveLimiter
+    epoch   int
+    release func()
+}
+
+// 占用一个下载名额，未开启 -adaptive-concurrency 时使用固定的 downloadSem
+func acquireDownload(ctx context.Context) (*downloadSlot, error) {
+    if downloadLimiter != nil {
+        return downloadLimiter.acquire(ctx)
+    }
+    release, err := acquire(ctx, downloadSem)
+    if err != nil {
+        return nil, err
+    }
+    return &downloadSlot{release: release}, nil
+}
+
+// 反馈一次下载尝试的结果，固定并发时什么也不做
+func (s *downloadSlot) report(err error) {
+    if s.limiter != nil {
+        s.limiter.report(err, s.epoch)
+    }
+}
+
+func (l *adaptiveLimiter) acquire(ctx context.Context) (*downloadSlot, error) {
+    for {
+        l.mu.Lock()
+        if l.inUse < int(l.limit) {
+            l.inUse++
+            slot := &downloadSlot{limiter: l, epoch: l.epoch, release: l.release}
+            l.mu.Unlock()
+            return slot, nil
+        }
+        wake := l.wake
+        l.mu.Unlock()
+
+        select {
+        case <-wake:
+        case <-ctx.Done():
+            return nil, context.Cause(ctx)
+        }
+    }
+}
+
+func (l *adaptiveLimiter) release() {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.inUse--
+    l.broadcast()
+}
+
+func (l *adaptiveLimiter) report(err error, epoch int) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    old := int(l.limit)
+    switch {
+    case err == nil:
+        if l.limit < l.thresh {
+            l.limit++
+        } else {
+            l.limit += 1 / l.limit
+        }
+        l.limit = min(l.limit, float64(l.max))
+    case !congestionError(err) || epoch != l.epoch:
+        return
+    default:
+        l.thresh = max(l.limit/2, 1)
+        l.limit = l.thresh
+        l.epoch++
+    }
+    if now := int(l.limit); now != old {
+        if now > old {
+            logf(levelPhase, "\n📶 自适应下载并发: %d -> %d\n", old, now)
+        } else {
+            logf(levelSummary, "\n📉 下载失败，自适应下载并发: %d -> %d\n", old, now)
+        }
+        l.broadcast()
+    }
+}
+
+// 调用方需持有 mu
+func (l *adaptiveLimiter) broadcast() {
+    close(l.wake)
+    l.wake = make(chan struct{})
+}
+
+// 说明镜像或链路承受不住的失败才减半；404 等客户端错误和主动取消不算
+func congestionError(err error) bool {
+    return retryable(err) && !errors.Is(err, context.Canceled) && !errors.Is(err, errDeadline)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+    "bytes"
+    "debug/elf"
+    "debug/macho"
+    "debug/pe"
+    "encoding/binary"
+    "fmt"
+    "strings"
+)
+
+var elfMachines = map[string]elf.Machine{
+    "x64":     elf.EM_X86_64,
+    "arm64":   elf.EM_AARCH64,
+    "armv7l":  elf.EM_ARM,
+    "armv6l":  elf.EM_ARM,
+    "x86":     elf.EM_386,
+    "riscv64": elf.EM_RISCV,
+    "loong64": elf.EM_LOONGARCH,
+}
+
+var machoCPUs = map[string]macho.Cpu{
+    "x64":   macho.CpuAmd64,
+    "arm64": macho.CpuArm64,
+}
+
+var peMachines = map[string]uint16{
+    "x64":   pe.IMAGE_FILE_MACHINE_AMD64,
+    "arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+    "x86":   pe.IMAGE_FILE_MACHINE_I386,
+}
+
+// 解析可执行文件头，确认机器类型与目标平台一致，防止镜像返回错误的文件
+func verifyBinaryArch(path, platform string) error {
+    osName, _, _ := strings.Cut(platform, "-")
+    arch := platformArch(platform)
+
+    switch osName {
+    case "linux":
+        f, err := elf.Open(path)
+        if err != nil {
+            return fmt.Errorf("解析 ELF 失败: %w", err)
+        }
+        defer f.Close()
+        if want, ok := elfMachines[arch]; ok && f.Machine != want {
+            return fmt.Errorf("架构不匹配: %s 期望 %v，实际 %v", platform, want, f.Machine)
+        }
+    case "darwin":
+        f, err := macho.Open(path)
+        if err != nil {
+            return fmt.Errorf("解析 Mach-O 失败: %w", err)
+        }
+        defer f.Close()
+        if want, ok := machoCPUs[arch]; ok && f.Cpu != want {
+            return fmt.Errorf("架构不匹配: %s 期望 %v，实际 %v", platform, want, f.Cpu)
+        }
+    case "win":
+        f, err := pe.Open(path)
+        if err != nil {
+            return fmt.Errorf("解析 PE 失败: %w", err)
+        }
+        defer f.Close()
+        if want, ok := peMachines[arch]; ok && f.Machine != want {
+            return fmt.Errorf("架构不匹配: %s 期望 %#x，实际 %#x", platform, want, f.Machine)
+        }
+    }
+    return nil
+}
+
+// 流式处理时只能看到可执行文件开头的若干字节，直接读文件头中的机器类型字段
+// 只支持 ELF 和 64 位 Mach-O (Windows 目标不走流式处理)
+func verifyArchHeader(hdr []byte, platform string) error {
+    osName, _, _ := strings.Cut(platform, "-")
+    arch := platformArch(platform)
+
+    switch osName {
+    case "linux":
+        if len(hdr) < 20 || !bytes.HasPrefix(hdr, []byte(elf.ELFMAG)) {
+            return fmt.Errorf("解析 ELF 失败: 文件头无效")
+        }
+        var order binary.ByteOrder = binary.LittleEndian
+        if elf.Data(hdr[elf.EI_DATA]) == elf.ELFDATA2MSB {
+            order = binary.BigEndian
+        }
+        got := elf.Machine(order.Uint16(hdr[18:20]))
+        if want, ok := elfMachines[arch]; ok && got != want {
+            return fmt.Errorf("架构不匹配: %s 期望 %v，实际 %v", platform, want, got)
+        }
+    case "darwin":
+        if len(hdr) < 8 || binary.LittleEndian.Uint32(hdr) != macho.Magic64 {
+            return fmt.Errorf("解析 Mach-O 失败: 文件头无效")
+        }
+        got := macho.Cpu(binary.LittleEndian.Uint32(hdr[4:8]))
+        if want, ok := machoCPUs[arch]; ok && got != want {
+            return fmt.Errorf("架构不匹配: %s 期望 %v，实际 %v", platform, want, got)
+        }
+    }
+    return nil
+}
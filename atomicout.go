@@ -0,0 +1,66 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// -replace-existing-atomic: 每轮先构建到 <out>.releases/<时间戳>/，全部目标成功后才切换到 -out，
+// 对外提供服务的目录始终是一套完整、一致的输出；有失败时丢弃这次构建，-out 保持原样
+// Unix 上 -out 是指向当前版本目录的符号链接，切换即原子地替换链接；Windows 见 swapdir_windows.go
+
+// 为本轮创建暂存目录，返回其路径
+func stageOutput(live string) (string, error) {
+    release := filepath.Join(live+".releases", time.Now().Format("20060102-150405.000000000"))
+    if err := os.MkdirAll(longPath(release), 0o755); err != nil {
+        return "", fmt.Errorf("创建暂存目录失败: %w", err)
+    }
+    logf(levelPhase, "📂 输出先写入暂存目录 %s\n", release)
+    return release, nil
+}
+
+// 把暂存目录发布为 live，并把结果中的输出路径改成发布后的路径
+func publishStaged(live, staged string, results []*targetResult) error {
+    if err := publishDir(live, staged); err != nil {
+        return fmt.Errorf("切换输出目录失败 (新构建保留在 %s): %w", staged, err)
+    }
+    for _, res := range results {
+        if rel, ok := strings.CutPrefix(res.OutFile, longPath(staged)); ok {
+            res.OutFile = longPath(live) + rel
+        }
+        for i, file := range res.Uploads {
+            if rel, ok := strings.CutPrefix(file, longPath(staged)); ok {
+                res.Uploads[i] = longPath(live) + rel
+            }
+        }
+    }
+    logf(levelSummary, "🔀 已切换 %s -> %s\n", live, staged)
+    return nil
+}
+
+// 有目标失败时丢弃暂存目录
+func discardStaged(live, staged string) {
+    logf(levelSummary, "⏭️  有目标失败，丢弃暂存目录，%s 保持不变\n", live)
+    os.RemoveAll(longPath(staged))
+}
+
+// 删除 <out>.releases 中除 keep 以外的旧版本目录
+func pruneReleases(live string, keep ...string) {
+    dir := live + ".releases"
+    entries, err := os.ReadDir(longPath(dir))
+    if err != nil {
+        return
+    }
+    kept := map[string]bool{}
+    for _, k := range keep {
+        kept[filepath.Base(k)] = true
+    }
+    for _, e := range entries {
+        if !kept[e.Name()] {
+            os.RemoveAll(longPath(filepath.Join(dir, e.Name())))
+        }
+    }
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+// -benchmark-size 的默认值和上限: 足够测出稳定速度，又不至于在按流量计费的线路上浪费
+const (
+    defaultBenchmarkSize = 2 << 20
+    maxBenchmarkSize     = 64 << 20
+    benchmarkTimeout     = 30 * time.Second // 单个镜像的上限，不可达的镜像不会拖住整个测速
+)
+
+// -benchmark 中一个镜像的测量结果
+type benchmarkResult struct {
+    Name      string  `json:"name"` // 预设名，或 current (-mirror/-source)、cross-check
+    Mirror    string  `json:"mirror"`
+    URL       string  `json:"url"`
+    LatencyMS int64   `json:"latencyMs"` // 发出请求到收到响应头
+    Bytes     int64   `json:"bytes"`
+    Speed     float64 `json:"bytesPerSecond"`
+    Error     string  `json:"error,omitempty"`
+}
+
+// -benchmark: 从每个镜像下载同一个压缩包的开头 -benchmark-size 字节，按速度排名，不产生任何输出文件
+// 候选为所有 -mirror-preset、当前的 -mirror/-source 和 -cross-check-mirrors；镜像之间依次测量，避免互相抢带宽
+func runBenchmark(ctx context.Context) error {
+    version, err := resolveVersion(ctx)
+    if err != nil {
+        return err
+    }
+    platform, err := hostPlatform()
+    if err != nil {
+        platform = "linux-x64"
+    }
+
+    results := benchmarkCandidates(version, platform)
+    if !opts.BenchmarkJSON {
+        logf(levelSummary, "🏁 测速: %s %s，每个镜像下载 %s\n", version, platform, formatBytes(opts.BenchmarkSize))
+    }
+    for _, r := range results {
+        probeMirror(ctx, r)
+        if !opts.BenchmarkJSON {
+            if r.Error != "" {
+                logf(levelSummary, "   %-10s ❌ %s\n", r.Name, r.Error)
+            } else {
+                logf(levelSummary, "   %-10s %s/s，延迟 %dms\n", r.Name, formatBytes(int64(r.Speed)), r.LatencyMS)
+            }
+        }
+    }
+    // 成功的按速度从快到慢，失败的排在最后
+    sort.SliceStable(results, func(i, j int) bool {
+        if (results[i].Error == "") != (results[j].Error == "") {
+            return results[i].Error == ""
+        }
+        return results[i].Speed > results[j].Speed
+    })
+
+    if opts.BenchmarkJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        return enc.Encode(results)
+    }
+    logf(levelSummary, "\n📊 排名:\n")
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    for i, r := range results {
+        if r.Error != "" {
+            fmt.Fprintf(tw, "  -\t%s\t失败\t\t%s\n", r.Name, r.Mirror)
+            continue
+        }
+        fmt.Fprintf(tw, "  %d\t%s\t%s/s\t%dms\t%s\n", i+1, r.Name, formatBytes(int64(r.Speed)), r.LatencyMS, r.Mirror)
+    }
+    return tw.Flush()
+}
+
+// 列出要测速的镜像，按地址去重
+func benchmarkCandidates(version, platform string) []*benchmarkResult {
+    var results []*benchmarkResult
+    seen := map[string]bool{}
+    add := func(name, mirror, url string) {
+        if seen[url] {
+            return
+        }
+        seen[url] = true
+        results = append(results, &benchmarkResult{Name: name, Mirror: mirror, URL: url})
+    }
+
+    names := make([]string, 0, len(mirrorPresets))
+    for name := range mirrorPresets {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        base := mirrorPresets[name].Dist
+        add(name, base, (&distSource{Base: base}).ArchiveURL(version, platform))
+    }
+    // 当前的 -mirror/-source 与某个预设相同时已在上面测过
+    current := mirrorBase()
+    if opts.Source == "github" {
+        current = "https://github.com/" + opts.GitHubRepo
+    }
+    add("current", current, buildURL(version, platform))
+    for _, m := range opts.CrossCheckMirrors {
+        base := strings.TrimRight(m, "/")
+        add("cross-check", base, (&distSource{Base: base}).ArchiveURL(version, platform))
+    }
+    return results
+}
+
+// 用 Range 请求下载 url 开头的 -benchmark-size 字节，记录延迟和速度；不重试，失败记在 Error 中
+func probeMirror(ctx context.Context, r *benchmarkResult) {
+    ctx, cancel := context.WithTimeout(ctx, benchmarkTimeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+    if err != nil {
+        r.Error = err.Error()
+        return
+    }
+    req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", opts.BenchmarkSize-1))
+    started := time.Now()
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        r.Error = err.Error()
+        return
+    }
+    defer resp.Body.Close()
+    r.LatencyMS = time.Since(started).Milliseconds()
+    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+        r.Error = newHTTPStatusError(resp).Error()
+        return
+    }
+    // 不支持 Range 的镜像会返回整个文件，只读到上限为止
+    bodyStart := time.Now()
+    r.Bytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, opts.BenchmarkSize))
+    if err != nil {
+        r.Error = err.Error()
+        return
+    }
+    if elapsed := time.Since(bodyStart).Seconds(); r.Bytes > 0 && elapsed > 0 {
+        r.Speed = float64(r.Bytes) / elapsed
+    }
+}
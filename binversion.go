@@ -0,0 +1,53 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+)
+
+// node 可执行文件内嵌的版本字符串，如 User-Agent 中的 node.js/v20.11.0
+// 以及下载地址中的 /release/v20.11.0/
+var binaryVersionRe = regexp.MustCompile(`(?:node\.js/|/release/)(v\d+\.\d+\.\d+)`)
+
+// 尽力从可执行文件中读出 node 版本，分块扫描，块之间保留重叠避免漏掉跨块的匹配
+func detectBinaryVersion(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    const chunk, overlap = 1 << 20, 64
+    buf := make([]byte, chunk+overlap)
+    keep := 0
+    for {
+        n, err := io.ReadFull(f, buf[keep:])
+        data := buf[:keep+n]
+        if m := binaryVersionRe.FindSubmatch(data); m != nil {
+            return string(m[1]), nil
+        }
+        if err == io.EOF || err == io.ErrUnexpectedEOF {
+            return "", fmt.Errorf("未在可执行文件中找到版本字符串")
+        }
+        if err != nil {
+            return "", err
+        }
+        keep = copy(buf, data[len(data)-overlap:])
+    }
+}
+
+// -verify-version: 对比可执行文件内嵌版本与期望版本，不一致或无法识别时只警告
+func checkBinaryVersion(exeFile, version, platform string) {
+    got, err := detectBinaryVersion(exeFile)
+    if err != nil {
+        logf(levelError, "⚠️  [%s] 无法识别可执行文件版本: %v\n", platform, err)
+        return
+    }
+    if got != version {
+        logf(levelError, "⚠️  [%s] 可执行文件版本 %s 与期望的 %s 不一致，镜像可能提供了错误的压缩包\n", platform, got, version)
+        return
+    }
+    logf(levelPhase, "版本[%s] %s 一致\n", platform, got)
+}
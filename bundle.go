@@ -0,0 +1,166 @@
+package main
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "strings"
+)
+
+// 遍历压缩包成员时的回调，name 为包内原始路径，符号链接的 linkname 非空
+type memberFunc func(name string, mode, size int64, linkname string, r io.Reader) error
+
+// 去掉包内路径的顶层目录 (node-vX.Y.Z-platform/)
+func memberRelPath(name string) string {
+    name = strings.TrimPrefix(name, "./")
+    if i := strings.Index(name, "/"); i >= 0 {
+        return name[i+1:]
+    }
+    return name
+}
+
+// 判断成员是否命中 -extra，以 / 结尾的模式按目录前缀匹配，其余按 glob 匹配
+func matchExtra(rel string) bool {
+    for _, pat := range opts.Extra {
+        if strings.HasSuffix(pat, "/") {
+            if strings.HasPrefix(rel, pat) {
+                return true
+            }
+            continue
+        }
+        if ok, _ := path.Match(pat, rel); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// 解压出 node 可执行文件和附加成员，以 tar 流按 -format 压缩写入 output
+func compressBundle(archivePath, output, platform string) error {
+    pr, pw := io.Pipe()
+    go func() {
+        pw.CloseWithError(extractBundle(archivePath, pw, platform))
+    }()
+    err := compressReader(pr, -1, output, platform)
+    // 压缩失败时让解压端尽快退出
+    pr.CloseWithError(err)
+    return err
+}
+
+// 将 node 可执行文件连同 -extra/-extract-dir 选中的成员以 tar 格式写入 w
+func extractBundle(archivePath string, w io.Writer, platform string) error {
+    tw := tar.NewWriter(w)
+    xo := extractOptions()
+    found, count := false, 0
+    add := func(name string, mode, size int64, linkname string, r io.Reader) error {
+        rel := memberRelPath(name)
+        isNode := rel == "node.exe" || (!strings.HasPrefix(platform, "win") && xo.isNodeTarMember(name))
+        if !isNode && !matchExtra(rel) {
+            return nil
+        }
+        found = found || isNode
+        count++
+        hdr := &tar.Header{Name: rel, Mode: mode, Size: size}
+        if linkname != "" {
+            hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, linkname, 0
+        }
+        if err := tw.WriteHeader(hdr); err != nil {
+            return err
+        }
+        _, err := io.Copy(tw, r)
+        return err
+    }
+
+    var err error
+    if strings.HasPrefix(platform, "win") {
+        err = walkZip(archivePath, xo, add)
+    } else {
+        err = walkTar(archivePath, archiveFormat(platform), xo, add)
+    }
+    if err != nil {
+        return err
+    }
+    if !found {
+        return fmt.Errorf("未找到 node 可执行文件")
+    }
+    if err := tw.Close(); err != nil {
+        return err
+    }
+    logf(levelPhase, "打包[%s] %d 个文件完成\n", platform, count)
+    return nil
+}
+
+func walkZip(zipPath string, o ExtractOptions, fn memberFunc) error {
+    r, err := zip.OpenReader(zipPath)
+    if err != nil {
+        return err
+    }
+    defer r.Close()
+
+    for _, f := range r.File {
+        if f.FileInfo().IsDir() {
+            continue
+        }
+        if err := checkMember(f.Name, ""); err != nil {
+            return err
+        }
+        rc, err := f.Open()
+        if err != nil {
+            return err
+        }
+        mr, err := o.limitMember(f.Name, zipDeclaredSize(f), rc)
+        if err == nil {
+            err = fn(f.Name, int64(f.Mode().Perm()), int64(f.UncompressedSize64), "", mr)
+        }
+        rc.Close()
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func walkTar(tarPath string, format ArchiveFormat, o ExtractOptions, fn memberFunc) error {
+    f, err := os.Open(tarPath)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    r, err := tarStream(f, format)
+    if err != nil {
+        return err
+    }
+    tr := tar.NewReader(r)
+
+    for {
+        h, err := tr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        linkname := ""
+        switch h.Typeflag {
+        case tar.TypeReg:
+        case tar.TypeSymlink:
+            linkname = h.Linkname
+        default:
+            continue
+        }
+        if err := checkMember(h.Name, linkname); err != nil {
+            return err
+        }
+        mr, err := o.limitMember(h.Name, h.Size, tr)
+        if err != nil {
+            return err
+        }
+        if err := fn(h.Name, h.Mode, h.Size, linkname, mr); err != nil {
+            return err
+        }
+    }
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "net/http"
+    "os"
+    "regexp"
+    "strings"
+    "sync"
+
+    "lukechampine.com/blake3"
+)
+
+// 远程 SHASUMS256.txt 的异步结果: 与首批下载并行获取，目标在校验阶段才等待
+type shasumsFuture struct {
+    once sync.Once
+    done chan struct{}
+    sums map[string]string
+    err  error
+}
+
+// 按地址区分: 默认来源在开始时预取，-targets-file 中的 baseURL 在首个目标用到时获取
+var (
+    remoteSumsMu sync.Mutex
+    remoteSums   = map[string]*shasumsFuture{}
+)
+
+// 由 resetMetadataCache 调用，常驻模式下每轮重新获取
+func resetRemoteSums() {
+    remoteSumsMu.Lock()
+    defer remoteSumsMu.Unlock()
+    remoteSums = map[string]*shasumsFuture{}
+}
+
+// 返回 url 处的 SHASUMS256.txt，首次调用时在后台开始获取
+func remoteShasums(ctx context.Context, url string) *shasumsFuture {
+    remoteSumsMu.Lock()
+    defer remoteSumsMu.Unlock()
+    f, ok := remoteSums[url]
+    if !ok {
+        f = &shasumsFuture{done: make(chan struct{})}
+        remoteSums[url] = f
+    }
+    f.start(ctx, url)
+    return f
+}
+
+// 后台开始获取，多次调用只生效一次
+func (f *shasumsFuture) start(ctx context.Context, url string) {
+    f.once.Do(func() {
+        go func() {
+            defer close(f.done)
+            f.sums, f.err = fetchShasums(ctx, url)
+        }()
+    })
+}
+
+// 等待获取完成，失败时所有目标都拿到同一个错误
+func (f *shasumsFuture) wait(ctx context.Context) (map[string]string, error) {
+    select {
+    case <-f.done:
+        return f.sums, f.err
+    case <-ctx.Done():
+        return nil, context.Cause(ctx)
+    }
+}
+
+// -verify-gpg 时改为获取签名版 SHASUMS256.txt.asc，缺失或签名无效都直接失败
+func fetchShasums(ctx context.Context, url string) (map[string]string, error) {
+    name := "SHASUMS256.txt"
+    if opts.VerifyGPG {
+        url += ".asc"
+        name += ".asc"
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("获取 %s 失败: %w", name, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("获取 %s 失败: %w", name, newHTTPStatusError(resp))
+    }
+    if !opts.VerifyGPG {
+        return parseShasums(resp.Body)
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return nil, fmt.Errorf("获取 %s 失败: %w", name, err)
+    }
+    signed, err := verifyClearsigned(data, url)
+    if err != nil {
+        return nil, err
+    }
+    return parseShasums(bytes.NewReader(signed))
+}
+
+// 获取 SHASUMS256.txt 失败时的错误类型: 签名无效算校验错误，其余算下载错误
+func shasumsError(platform, url string, err error) error {
+    var sig *signatureError
+    if errors.As(err, &sig) {
+        return &ChecksumError{Platform: platform, URL: url, Err: err}
+    }
+    return &DownloadError{Platform: platform, URL: url, Err: err}
+}
+
+// BSD 风格的校验和行: SHA256 (<文件名>) = <哈希>，算法名也可以是 SHA512、BLAKE3
+var bsdSumRe = regexp.MustCompile(`^[A-Z0-9]+ \((.+)\) = ([0-9A-Fa-f]{64,128})$`)
+
+// 解析 SHASUMS256.txt 或 -checksum-algo 对应的校验和文件，返回 文件名 -> 十六进制哈希
+// 同时接受 GNU 风格 (<哈希>  <文件名>) 和 BSD 风格的行
+func parseShasums(r io.Reader) (map[string]string, error) {
+    sums := make(map[string]string)
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        if m := bsdSumRe.FindStringSubmatch(strings.TrimSpace(sc.Text())); m != nil {
+            sums[m[1]] = strings.ToLower(m[2])
+            continue
+        }
+        fields := strings.Fields(sc.Text())
+        if len(fields) != 2 {
+            continue
+        }
+        sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+    }
+    return sums, sc.Err()
+}
+
+// 计算文件的 SHA-256
+func fileSHA256(path string) (string, error) {
+    return fileHash(path, sha256.New)
+}
+
+func fileHash(path string, newHash func() hash.Hash) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    h := newHash()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 输出校验和文件使用的算法，由 -checksum-algo 选择
+// 源压缩包的校验始终是 SHA-256，因为 Node 官方只发布 SHASUMS256.txt
+type checksumAlgo struct {
+    File   string // 构建和 -compress-only 写出、-verify-only 读取的校验和文件名
+    BSDTag string // BSD 风格行首的算法名
+    New    func() hash.Hash
+}
+
+var checksumAlgos = map[string]checksumAlgo{
+    "sha256": {File: "SHASUMS256.txt", BSDTag: "SHA256", New: sha256.New},
+    "sha512": {File: "SHA512SUMS", BSDTag: "SHA512", New: sha512.New},
+    "blake3": {File: "B3SUMS", BSDTag: "BLAKE3", New: func() hash.Hash { return blake3.New(32, nil) }},
+}
+
+// 当前 -checksum-algo 对应的算法，取值已在启动时验证过
+func outputChecksumAlgo() checksumAlgo {
+    return checksumAlgos[opts.ChecksumAlgo]
+}
+
+// 输出校验和文件名: -checksum-algo 对应的文件名加上 -prefix，不同前缀的输出在同一目录中各有一份
+func outputShasumsName() string {
+    return opts.Prefix + outputChecksumAlgo().File
+}
+
+// -checksum-mode 的取值
+const (
+    checksumRequired  = "required"   // 未列出即失败
+    checksumIfPresent = "if-present" // 列出时校验，未列出时跳过 (非官方构建常见)
+    checksumOff       = "off"
+)
+
+// 压缩包哈希与 SHASUMS256.txt 不符
+type checksumMismatch struct {
+    Name, Want, Got string
+}
+
+func (e *checksumMismatch) Error() string {
+    return fmt.Sprintf("校验和不匹配: %s 期望 %s，实际 %s", e.Name, e.Want, e.Got)
+}
+
+// 校验 name 的哈希并输出结果
+// sums 中未列出时按 -checksum-mode 决定失败还是跳过
+func verifyChecksum(sums map[string]string, name, sum, platform string) error {
+    want, ok := sums[name]
+    if !ok {
+        if opts.ChecksumMode == checksumRequired {
+            return fmt.Errorf("SHASUMS256.txt 中没有 %s (-checksum-mode required)", name)
+        }
+        logf(levelSummary, "⚠️  校验[%s] SHASUMS256.txt 中没有 %s，跳过校验\n", platform, name)
+        return nil
+    }
+    if want != sum {
+        return &checksumMismatch{Name: name, Want: want, Got: sum}
+    }
+    logf(levelPhase, "校验[%s] SHA-256 通过\n", platform)
+    return nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+    "os"
+)
+
+// 按 -chown 修改输出文件属主，便于容器内以 root 运行时写入挂载目录
+func chownOutput(path string) error {
+    if opts.ChownUID < 0 {
+        return nil
+    }
+    return os.Chown(path, opts.ChownUID, opts.ChownGID)
+}
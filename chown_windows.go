@@ -0,0 +1,20 @@
+//go:build windows
+
+package main
+
+import (
+    "sync"
+)
+
+var chownWarnOnce sync.Once
+
+// Windows 没有 UID/GID，-chown 只给出一次警告
+func chownOutput(path string) error {
+    if opts.ChownUID < 0 {
+        return nil
+    }
+    chownWarnOnce.Do(func() {
+        logf(levelError, "⚠️  Windows 不支持 -chown，已忽略\n")
+    })
+    return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "time"
+
+    "golang.org/x/net/proxy"
+)
+
+// 所有请求共用的 HTTP 客户端
+var httpClient = http.DefaultClient
+
+// 构建共享 Transport
+// 代理优先级: -socks5 > HTTP_PROXY/HTTPS_PROXY 环境变量 > 直连
+// 连接、TLS 握手和等待响应头各有超时，让不响应的镜像尽快失败进入重试；响应体的传输不设总时限，
+// 慢但持续有数据的下载不会被中断 (单次尝试的总时限见 -targets-file 的 timeout)
+func newHTTPClient() (*http.Client, error) {
+    dialer := &net.Dialer{
+        Timeout:   opts.DialTimeout,
+        KeepAlive: 30 * time.Second,
+    }
+
+    if opts.BindIP != "" {
+        addr, err := bindAddr(opts.BindIP)
+        if err != nil {
+            return nil, err
+        }
+        dialer.LocalAddr = addr
+    }
+
+    tr := http.DefaultTransport.(*http.Transport).Clone()
+    tr.Proxy = http.ProxyFromEnvironment
+    tr.DialContext = dialer.DialContext
+    tr.TLSHandshakeTimeout = opts.TLSTimeout
+    tr.ResponseHeaderTimeout = opts.HeaderTimeout
+
+    if opts.Socks5 != "" {
+        d, err := proxy.SOCKS5("tcp", opts.Socks5, nil, dialer)
+        if err != nil {
+            return nil, fmt.Errorf("SOCKS5 代理配置失败: %w", err)
+        }
+        cd, ok := d.(proxy.ContextDialer)
+        if !ok {
+            return nil, fmt.Errorf("SOCKS5 代理不支持 DialContext")
+        }
+        // SOCKS5 负责拨号时不再叠加 HTTP 代理
+        tr.Proxy = nil
+        tr.DialContext = cd.DialContext
+    }
+
+    if opts.HTTPTrace != "" {
+        tt, err := newTraceTransport(tr, opts.HTTPTrace)
+        if err != nil {
+            return nil, fmt.Errorf("打开 -http-trace 文件失败: %w", err)
+        }
+        return &http.Client{Transport: tt}, nil
+    }
+    return &http.Client{Transport: tr}, nil
+}
+
+// 解析 -bind-ip 并确认本机确有该地址，避免到第一次下载时才报出含糊的拨号错误
+func bindAddr(ip string) (*net.TCPAddr, error) {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return nil, fmt.Errorf("-bind-ip 不是有效的 IP 地址: %q", ip)
+    }
+    addr := &net.TCPAddr{IP: parsed}
+    l, err := net.ListenTCP("tcp", addr)
+    if err != nil {
+        return nil, fmt.Errorf("-bind-ip 无法绑定 %s (本机没有该地址?): %w", ip, err)
+    }
+    l.Close()
+    return addr, nil
+}
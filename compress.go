@@ -0,0 +1,251 @@
+package main
+
+import (
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+
+    "github.com/andybalholm/brotli"
+    "github.com/klauspost/compress/zstd"
+)
+
+// 一种输出压缩格式: 扩展名、编码器和用于 -verify-only 的解码器
+// 新增格式只需在 compressors 中加一项
+type compressor struct {
+    Ext         string
+    ContentType string
+    NewWriter func(w io.Writer, platform string) (io.WriteCloser, error)
+    NewReader func(r io.Reader) (io.Reader, error)
+}
+
+var compressors = map[string]compressor{
+    "zstd": {
+        Ext:         ".zst",
+        ContentType: "application/zstd",
+        NewWriter: func(w io.Writer, platform string) (io.WriteCloser, error) {
+            _, level := zstd.EncoderLevelFromString(zstdLevelFor(platform))
+            eopts := []zstd.EOption{zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(zstdThreads)}
+            // 只在 -max-memory 调低窗口时设置，否则保留各级别自己的默认窗口
+            if zstdWindow < defaultZstdWindow {
+                eopts = append(eopts, zstd.WithWindowSize(zstdWindow))
+            }
+            if zstdDict != nil {
+                eopts = append(eopts, zstd.WithEncoderDict(zstdDict))
+            }
+            return zstd.NewWriter(w, eopts...)
+        },
+        NewReader: func(r io.Reader) (io.Reader, error) {
+            var dopts []zstd.DOption
+            if zstdDict != nil {
+                dopts = append(dopts, zstd.WithDecoderDicts(zstdDict))
+            }
+            dec, err := zstd.NewReader(r, dopts...)
+            if err != nil {
+                return nil, err
+            }
+            return dec.IOReadCloser(), nil
+        },
+    },
+    "gzip": {
+        Ext:         ".gz",
+        ContentType: "application/gzip",
+        NewWriter: func(w io.Writer, platform string) (io.WriteCloser, error) {
+            gw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+            if err != nil {
+                return nil, err
+            }
+            // gzip 头能记录原文件名，gunzip -N 解压时据此命名
+            gw.Name = originalName(platform)
+            return gw, nil
+        },
+        NewReader: func(r io.Reader) (io.Reader, error) {
+            return gzip.NewReader(r)
+        },
+    },
+    "brotli": {
+        Ext:         ".br",
+        ContentType: "application/x-brotli",
+        NewWriter: func(w io.Writer, platform string) (io.WriteCloser, error) {
+            return brotli.NewWriterLevel(w, opts.BrotliQuality), nil
+        },
+        NewReader: func(r io.Reader) (io.Reader, error) {
+            return brotli.NewReader(r), nil
+        },
+    },
+}
+
+// 按扩展名查找压缩格式，用于校验已有输出
+func compressorByExt(name string) (compressor, bool) {
+    for _, c := range compressors {
+        if strings.HasSuffix(name, c.Ext) {
+            return c, true
+        }
+    }
+    return compressor{}, false
+}
+
+// 校验 -format 及对应的级别参数
+func validateFormat() error {
+    if opts.AlsoGzip && opts.Format == "gzip" {
+        return fmt.Errorf("-format gzip 时不需要 -also-gzip")
+    }
+    if _, ok := compressors[opts.Format]; !ok {
+        names := make([]string, 0, len(compressors))
+        for name := range compressors {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        return fmt.Errorf("-format 只能是 %s: %q", strings.Join(names, "、"), opts.Format)
+    }
+    if ok, _ := zstd.EncoderLevelFromString(opts.ZstdLevel); !ok {
+        return fmt.Errorf("-zstd-level 只能是 fastest、default、better、best: %q", opts.ZstdLevel)
+    }
+    for arch, level := range opts.ZstdLevelByArch {
+        if ok, _ := zstd.EncoderLevelFromString(level); !ok {
+            return fmt.Errorf("-zstd-level-by-arch %s 的级别只能是 fastest、default、better、best: %q", arch, level)
+        }
+    }
+    if opts.BrotliQuality < brotli.BestSpeed || opts.BrotliQuality > brotli.BestCompression {
+        return fmt.Errorf("-brotli-quality 范围为 0-11: %d", opts.BrotliQuality)
+    }
+    return nil
+}
+
+// 平台的 CPU 架构部分，如 linux-armv7l 的 armv7l、linux-x64-musl 的 x64
+func platformArch(platform string) string {
+    _, rest, _ := strings.Cut(platform, "-")
+    arch, _, _ := strings.Cut(rest, "-")
+    return arch
+}
+
+// 目标使用的 zstd 级别: -targets-file 的 zstdLevel 优先，其次 -zstd-level-by-arch，最后 -zstd-level
+func zstdLevelFor(platform string) string {
+    if o, ok := targetOverrides[platform]; ok && o.ZstdLevel != "" {
+        return o.ZstdLevel
+    }
+    if level, ok := opts.ZstdLevelByArch[platformArch(platform)]; ok {
+        return level
+    }
+    return opts.ZstdLevel
+}
+
+// -zstd-level-by-arch 中的架构必须对应至少一个目标，防止拼写错误被静默忽略
+func validateZstdLevelByArch() error {
+    archs := map[string]bool{}
+    for _, platform := range targets {
+        archs[platformArch(platform)] = true
+    }
+    for arch := range opts.ZstdLevelByArch {
+        if !archs[arch] {
+            return fmt.Errorf("-zstd-level-by-arch 中的架构 %q 不对应任何目标", arch)
+        }
+    }
+    return nil
+}
+
+func compressFile(input, output, platform string) error {
+    in, err := os.Open(input)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    info, err := in.Stat()
+    if err != nil {
+        return err
+    }
+    return compressReader(in, info.Size(), output, platform)
+}
+
+// -also-gzip: 与主输出同名、扩展名为 .gz 的旁路输出
+func gzipSidecar(output string) string {
+    return strings.TrimSuffix(output, compressors[opts.Format].Ext) + ".gz"
+}
+
+// 主输出的 part 文件对应的旁路 part 文件，由 writeCompressed 随主输出一起改名
+func gzipPart(part string) string {
+    return part + ".gz"
+}
+
+// 与 writeAtomic 相同，-also-gzip 时同时把旁路输出从 part 改名到位，失败时一并清理
+func writeCompressed(output string, write func(part string) error) error {
+    if !opts.AlsoGzip {
+        return writeAtomic(output, write)
+    }
+    var sidePart string
+    err := writeAtomic(output, func(part string) error {
+        sidePart = gzipPart(part)
+        return write(part)
+    })
+    if err != nil {
+        os.Remove(sidePart)
+        return err
+    }
+    return os.Rename(sidePart, gzipSidecar(output))
+}
+
+// 按 -format 把 r 压缩写入 output，size 未知时传 -1；-also-gzip 时同一遍读同时写出旁路 .gz
+func compressReader(r io.Reader, size int64, output, platform string) error {
+    out, err := os.Create(output)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+    // side 保持为接口类型的 nil，避免把空的 *os.File 当作旁路输出
+    var side io.Writer
+    var sideFile *os.File
+    if opts.AlsoGzip {
+        if sideFile, err = os.Create(gzipPart(output)); err != nil {
+            return err
+        }
+        defer sideFile.Close()
+        side = sideFile
+    }
+
+    pw := &ProgressWriter{Phase: PhaseCompress, Subject: platform, Total: size}
+    if err := compressStream(out, io.TeeReader(r, pw), side, platform); err != nil {
+        return err
+    }
+    pw.Done()
+    if sideFile != nil {
+        if err := sideFile.Close(); err != nil {
+            return err
+        }
+    }
+    return out.Close()
+}
+
+// 按 -format 把 r 压缩写入 w；side 非 nil 时同时以 gzip 写入 side，两个编码器共用一遍读取
+func compressStream(w io.Writer, r io.Reader, side io.Writer, platform string) error {
+    if opts.Format == "zstd" {
+        logf(levelPhase, "\n压缩[%s] zstd 级别 %s\n", platform, zstdLevelFor(platform))
+    }
+    enc, err := compressors[opts.Format].NewWriter(w, platform)
+    if err != nil {
+        return err
+    }
+    encoders := []io.WriteCloser{enc}
+    if side != nil {
+        gz, err := compressors["gzip"].NewWriter(side, platform)
+        if err != nil {
+            return err
+        }
+        encoders = append(encoders, gz)
+    }
+    writers := make([]io.Writer, len(encoders))
+    for i, e := range encoders {
+        writers[i] = e
+    }
+
+    _, err = io.Copy(io.MultiWriter(writers...), r)
+    // Close 会写出最后的数据块，必须检查错误
+    for _, e := range encoders {
+        if cerr := e.Close(); err == nil {
+            err = cerr
+        }
+    }
+    return err
+}
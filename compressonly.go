@@ -0,0 +1,202 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// -compress-only: 把目录中已有的 node 可执行文件按当前格式、级别和命名压缩到 -out，
+// 并按 -checksum-algo 写出校验和文件 (可直接用 -verify-only 复查)；不解析版本、不下载也不解压
+func runCompressOnly() int {
+    if err := os.MkdirAll(longPath(opts.Out), 0o755); err != nil {
+        logf(levelError, "❌ %v\n", err)
+        return exitFailure
+    }
+    entries, err := os.ReadDir(opts.CompressOnly)
+    if err != nil {
+        logf(levelError, "❌ 读取 -compress-only 目录失败: %v\n", err)
+        return exitFailure
+    }
+
+    sums := map[string]string{}
+    var total, failed int
+    for _, e := range entries {
+        input := filepath.Join(opts.CompressOnly, e.Name())
+        if !e.Type().IsRegular() || !isExecutableFile(input) {
+            continue
+        }
+        total++
+        output, platform := compressOnlyTarget(e.Name())
+        start := time.Now()
+        err := compressOnlyFile(input, output, platform)
+        if err != nil {
+            failed++
+            logf(levelError, "❌ %s 失败: %v\n", e.Name(), err)
+            continue
+        }
+        files := []string{output}
+        if opts.AlsoGzip {
+            files = append(files, gzipSidecar(output))
+        }
+        if err := addShasums(sums, files); err != nil {
+            failed++
+            logf(levelError, "❌ %s 失败: %v\n", e.Name(), err)
+            continue
+        }
+        logf(levelSummary, "✅ 完成: %s (%s)\n", output, time.Since(start).Round(time.Millisecond))
+    }
+    if total == 0 {
+        logf(levelError, "❌ %s 中没有可执行文件\n", opts.CompressOnly)
+        return exitFailure
+    }
+    if len(sums) > 0 {
+        name := outputShasumsName()
+        if err := writeShasums(filepath.Join(opts.Out, name), sums); err != nil {
+            logf(levelError, "❌ 写出 %s 失败: %v\n", name, err)
+            return exitFailure
+        }
+    }
+    logf(levelSummary, "\n🎉 压缩完成: 成功 %d，失败 %d\n", total-failed, failed)
+    return exitCode(total, failed)
+}
+
+// 按 -raw-binary 的命名反查目标: node_linux_amd64 -> node_linux_amd64.zst (linux-x64)
+// 不认识的文件名直接加上压缩扩展名，平台名仅用于日志
+func compressOnlyTarget(name string) (output, platform string) {
+    for outFile, p := range targets {
+        if rawBinaryName(outFile, p) == name {
+            return outputName(opts.Out, outFile), p
+        }
+    }
+    return outputName(opts.Out, name), name
+}
+
+func compressOnlyFile(input, output, platform string) error {
+    if supportedPlatform(platform) {
+        if err := verifyBinaryArch(input, platform); err != nil {
+            return err
+        }
+    }
+    if err := writeCompressed(output, func(part string) error {
+        return compressFile(input, part, platform)
+    }); err != nil {
+        return err
+    }
+    if err := chownOutput(output); err != nil {
+        return err
+    }
+    if opts.AlsoGzip {
+        return chownOutput(gzipSidecar(output))
+    }
+    return nil
+}
+
+// 可执行文件的文件头: ELF、PE (MZ)、Mach-O 32/64 位及通用二进制
+var executableMagics = [][]byte{
+    []byte("\x7fELF"),
+    []byte("MZ"),
+    {0xcf, 0xfa, 0xed, 0xfe},
+    {0xce, 0xfa, 0xed, 0xfe},
+    {0xca, 0xfe, 0xba, 0xbe},
+}
+
+func isExecutableFile(path string) bool {
+    f, err := os.Open(path)
+    if err != nil {
+        return false
+    }
+    defer f.Close()
+    hdr := make([]byte, 4)
+    n, _ := io.ReadFull(f, hdr)
+    for _, m := range executableMagics {
+        if bytes.HasPrefix(hdr[:n], m) {
+            return true
+        }
+    }
+    return false
+}
+
+func addShasums(sums map[string]string, files []string) error {
+    for _, file := range files {
+        sum, err := fileHash(file, outputChecksumAlgo().New)
+        if err != nil {
+            return err
+        }
+        sums[filepath.Base(file)] = sum
+    }
+    return nil
+}
+
+// 构建结束后在 dir 中写出成功目标的输出 (含 -also-gzip 的 .gz) 的校验和文件，供 -verify-only 和下游校验
+// 已有的校验和文件中其余仍然存在的输出保留原条目，-only 或部分目标失败后重新构建时不会丢掉其他目标
+func writeOutputShasums(dir string, results []*targetResult) error {
+    path := filepath.Join(dir, outputShasumsName())
+    sums := map[string]string{}
+    if f, err := os.Open(path); err == nil {
+        old, err := parseShasums(f)
+        f.Close()
+        if err == nil {
+            for name, sum := range old {
+                if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+                    sums[name] = sum
+                }
+            }
+        }
+    }
+    built := 0
+    for _, res := range results {
+        if res.Err != nil {
+            continue
+        }
+        built++
+        files := []string{res.OutFile}
+        if opts.AlsoGzip && !opts.RawBinary {
+            files = append(files, gzipSidecar(res.OutFile))
+        }
+        if err := addShasums(sums, files); err != nil {
+            return err
+        }
+    }
+    if built == 0 {
+        return nil
+    }
+    return writeShasums(path, sums)
+}
+
+// 按文件名排序写出 sha256sum (或 sha512sum、b3sum) 格式的校验和文件
+func writeShasums(path string, sums map[string]string) error {
+    names := make([]string, 0, len(sums))
+    for name := range sums {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var b strings.Builder
+    for _, name := range names {
+        if opts.ChecksumFormat == "bsd" {
+            fmt.Fprintf(&b, "%s (%s) = %s\n", outputChecksumAlgo().BSDTag, name, sums[name])
+        } else {
+            fmt.Fprintf(&b, "%s  %s\n", sums[name], name)
+        }
+    }
+    if err := writeAtomic(path, func(part string) error {
+        return os.WriteFile(part, []byte(b.String()), 0o644)
+    }); err != nil {
+        return err
+    }
+    if signingKey != nil {
+        if err := signShasums(path); err != nil {
+            return fmt.Errorf("签名 %s 失败: %w", filepath.Base(path), err)
+        }
+        logf(levelSummary, "🔏 %s.asc\n", filepath.Base(path))
+    } else if _, err := os.Stat(path + ".asc"); err == nil {
+        logf(levelError, "⚠️  %s.asc 是旧内容的签名，已与新的 %s 不符，请加 -sign-key 重新签名或删除\n",
+            filepath.Base(path), filepath.Base(path))
+    }
+    return nil
+}
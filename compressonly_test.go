@@ -0,0 +1,138 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// 构建写出的校验和文件按 -checksum-algo 命名和计算，-checksum-format 决定格式，读回后与输出一致
+func TestWriteOutputShasums(t *testing.T) {
+    for _, algo := range []string{"sha256", "sha512", "blake3"} {
+        for _, format := range []string{"gnu", "bsd"} {
+            t.Run(algo+"-"+format, func(t *testing.T) {
+                saved := opts
+                t.Cleanup(func() { opts = saved })
+                opts.ChecksumAlgo, opts.ChecksumFormat = algo, format
+                opts.AlsoGzip, opts.RawBinary = false, false
+
+                dir := t.TempDir()
+                var results []*targetResult
+                for _, name := range []string{"node_linux_amd64.zst", "node_darwin_arm64.zst"} {
+                    out := filepath.Join(dir, name)
+                    if err := os.WriteFile(out, []byte(name), 0o644); err != nil {
+                        t.Fatal(err)
+                    }
+                    results = append(results, &targetResult{OutFile: out})
+                }
+                if err := writeOutputShasums(dir, results); err != nil {
+                    t.Fatal(err)
+                }
+                path := filepath.Join(dir, checksumAlgos[algo].File)
+                data, err := os.ReadFile(path)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if bsd := strings.HasPrefix(string(data), checksumAlgos[algo].BSDTag+" ("); bsd != (format == "bsd") {
+                    t.Fatalf("%s 格式不是 %s:\n%s", filepath.Base(path), format, data)
+                }
+                sums := readShasumsFile(t, path)
+                if len(sums) != len(results) {
+                    t.Fatalf("列出 %d 个文件，期望 %d", len(sums), len(results))
+                }
+                for _, res := range results {
+                    want, err := fileHash(res.OutFile, checksumAlgos[algo].New)
+                    if err != nil {
+                        t.Fatal(err)
+                    }
+                    if got := sums[filepath.Base(res.OutFile)]; got != want {
+                        t.Errorf("%s = %q，期望 %q", filepath.Base(res.OutFile), got, want)
+                    }
+                }
+            })
+        }
+    }
+}
+
+// 只重新构建部分目标时保留其余仍然存在的输出的条目，已删除的输出不再列出
+func TestWriteOutputShasumsKeepsOtherTargets(t *testing.T) {
+    saved := opts
+    t.Cleanup(func() { opts = saved })
+    opts.ChecksumAlgo, opts.ChecksumFormat = "sha256", "gnu"
+
+    dir := t.TempDir()
+    var results []*targetResult
+    for _, name := range []string{"node_linux_amd64.zst", "node_linux_arm64.zst", "node_win_amd64.exe.zst"} {
+        out := filepath.Join(dir, name)
+        if err := os.WriteFile(out, []byte(name), 0o644); err != nil {
+            t.Fatal(err)
+        }
+        results = append(results, &targetResult{OutFile: out})
+    }
+    if err := writeOutputShasums(dir, results); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Remove(results[2].OutFile); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(results[0].OutFile, []byte("rebuilt"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if err := writeOutputShasums(dir, results[:1]); err != nil {
+        t.Fatal(err)
+    }
+
+    sums := readShasumsFile(t, filepath.Join(dir, "SHASUMS256.txt"))
+    if _, ok := sums["node_win_amd64.exe.zst"]; ok {
+        t.Errorf("已删除的输出仍然列出")
+    }
+    for _, res := range results[:2] {
+        want, err := fileHash(res.OutFile, checksumAlgos["sha256"].New)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := sums[filepath.Base(res.OutFile)]; got != want {
+            t.Errorf("%s = %q，期望 %q", filepath.Base(res.OutFile), got, want)
+        }
+    }
+}
+
+func readShasumsFile(t *testing.T, path string) map[string]string {
+    t.Helper()
+    f, err := os.Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    sums, err := parseShasums(f)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return sums
+}
+
+// -prefix 同样作用于校验和文件名，不同前缀的构建写入同一目录时互不覆盖
+func TestWriteOutputShasumsPrefix(t *testing.T) {
+    saved := opts
+    t.Cleanup(func() { opts = saved })
+    opts.ChecksumAlgo, opts.ChecksumFormat = "sha512", "gnu"
+
+    dir := t.TempDir()
+    for _, prefix := range []string{"lts_", "current_"} {
+        opts.Prefix = prefix
+        out := filepath.Join(dir, prefix+"node_linux_amd64.zst")
+        if err := os.WriteFile(out, []byte(prefix), 0o644); err != nil {
+            t.Fatal(err)
+        }
+        if err := writeOutputShasums(dir, []*targetResult{{OutFile: out}}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    for _, prefix := range []string{"lts_", "current_"} {
+        sums := readShasumsFile(t, filepath.Join(dir, prefix+"SHA512SUMS"))
+        if _, ok := sums[prefix+"node_linux_amd64.zst"]; len(sums) != 1 || !ok {
+            t.Errorf("%sSHA512SUMS 列出 %v，期望只有 %snode_linux_amd64.zst", prefix, sums, prefix)
+        }
+    }
+}
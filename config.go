@@ -0,0 +1,198 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/BurntSushi/toml"
+    "gopkg.in/yaml.v3"
+)
+
+// 一层设置来源: 按参数名给出的值，列表类参数可以有多个值，依次传给 flag.Set
+type configLayer struct {
+    Name   string
+    Values map[string][]string
+    Label  func(flagName string) string // 出错时如何称呼该项，为空时用参数名
+}
+
+// 读取 -config 指定的 YAML (.yaml/.yml) 或 TOML (.toml) 文件
+// 键就是命令行参数名 (不带 -)，列表写成数组，键值类参数 (-zstd-level-by-arch、-s3-meta) 写成表
+func loadConfigFile(path string) (*configLayer, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    raw := map[string]any{}
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".yaml", ".yml":
+        err = yaml.Unmarshal(data, &raw)
+    case ".toml":
+        err = toml.Unmarshal(data, &raw)
+    default:
+        return nil, fmt.Errorf("只支持 .yaml、.yml 或 .toml: %s", path)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+    }
+
+    layer := &configLayer{Name: path, Values: map[string][]string{}}
+    var unknown []string
+    for key, v := range raw {
+        if flag.Lookup(key) == nil || key == "config" {
+            unknown = append(unknown, key)
+            continue
+        }
+        values, err := configValues(v)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %s: %w", path, key, err)
+        }
+        layer.Values[key] = values
+    }
+    if len(unknown) > 0 {
+        sort.Strings(unknown)
+        return nil, fmt.Errorf("%s 中有未知的参数: %s", path, strings.Join(unknown, ", "))
+    }
+    return layer, nil
+}
+
+// 把文件中的值转成 flag.Set 接受的字符串: 标量一个，数组每项一个，表每项一个 KEY=VALUE
+func configValues(v any) ([]string, error) {
+    switch x := v.(type) {
+    case []any:
+        out := make([]string, 0, len(x))
+        for _, item := range x {
+            s, err := configScalar(item)
+            if err != nil {
+                return nil, err
+            }
+            out = append(out, s)
+        }
+        return out, nil
+    case map[string]any:
+        keys := make([]string, 0, len(x))
+        for k := range x {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        out := make([]string, 0, len(x))
+        for _, k := range keys {
+            s, err := configScalar(x[k])
+            if err != nil {
+                return nil, err
+            }
+            out = append(out, k+"="+s)
+        }
+        return out, nil
+    }
+    s, err := configScalar(v)
+    if err != nil {
+        return nil, err
+    }
+    return []string{s}, nil
+}
+
+func configScalar(v any) (string, error) {
+    switch x := v.(type) {
+    case string, bool, int, int64, uint64, float64:
+        return fmt.Sprint(v), nil
+    case time.Time:
+        // 不加引号的日期，如 since-date: 2024-01-01
+        if x.Equal(x.Truncate(24 * time.Hour)) {
+            return x.Format(releaseDateLayout), nil
+        }
+        return x.Format(time.RFC3339), nil
+    case fmt.Stringer:
+        // TOML 的本地日期和时间
+        return x.String(), nil
+    }
+    return "", fmt.Errorf("不支持的值类型 %T", v)
+}
+
+// 环境变量前缀: 每个参数都有对应的 NODEDIST_<参数名大写，- 换成 _>，如 NODEDIST_ZSTD_LEVEL
+const envPrefix = "NODEDIST_"
+
+func envName(flagName string) string {
+    return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// 从 NODEDIST_* 环境变量收集设置，每个变量的值原样作为一次 flag.Set 的参数
+// 不对应任何参数的 NODEDIST_* 变量视为拼写错误
+func loadEnvLayer() (*configLayer, error) {
+    known := map[string]string{}
+    flag.VisitAll(func(f *flag.Flag) { known[envName(f.Name)] = f.Name })
+
+    layer := &configLayer{Name: "环境变量", Values: map[string][]string{}, Label: envName}
+    var unknown []string
+    for _, kv := range os.Environ() {
+        key, value, _ := strings.Cut(kv, "=")
+        if !strings.HasPrefix(key, envPrefix) {
+            continue
+        }
+        name, ok := known[key]
+        if !ok {
+            unknown = append(unknown, key)
+            continue
+        }
+        layer.Values[name] = []string{value}
+    }
+    if len(unknown) > 0 {
+        sort.Strings(unknown)
+        return nil, fmt.Errorf("未知的环境变量: %s", strings.Join(unknown, ", "))
+    }
+    return layer, nil
+}
+
+// 命令行解析之后合并其余设置来源，优先级: 命令行参数 > NODEDIST_* 环境变量 > -config 文件 > 默认值
+// 配置文件路径本身也可以来自 NODEDIST_CONFIG
+func loadConfigLayers() error {
+    env, err := loadEnvLayer()
+    if err != nil {
+        return err
+    }
+    if v, ok := env.Values["config"]; ok && !explicitFlags()["config"] {
+        opts.Config = v[0]
+    }
+    layers := []*configLayer{env}
+    if opts.Config != "" {
+        file, err := loadConfigFile(opts.Config)
+        if err != nil {
+            return fmt.Errorf("-config: %w", err)
+        }
+        layers = append(layers, file)
+    }
+    return applyConfigLayers(layers...)
+}
+
+// 按优先级从高到低合并各层设置: 命令行上给出的参数不被覆盖，同一参数以先出现的层为准
+// 值经 flag.Set 写入，与命令行参数走同一套解析和校验，之后 explicitFlags 也会把它们视为显式设置
+func applyConfigLayers(layers ...*configLayer) error {
+    set := explicitFlags()
+    for _, layer := range layers {
+        keys := make([]string, 0, len(layer.Values))
+        for key := range layer.Values {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+            if set[key] {
+                continue
+            }
+            set[key] = true
+            for _, v := range layer.Values[key] {
+                if err := flag.Set(key, v); err != nil {
+                    label := key
+                    if layer.Label != nil {
+                        label = layer.Label(key)
+                    }
+                    return fmt.Errorf("%s: %s=%q: %w", layer.Name, label, v, err)
+                }
+            }
+        }
+    }
+    return nil
+}
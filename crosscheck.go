@@ -0,0 +1,46 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// 主镜像的压缩包与 SHASUMS256.txt 不符时，依次从 -cross-check-mirrors 重新下载同一文件对比:
+//   - 某个镜像与 SHASUMS256.txt 一致: 主镜像的文件损坏，改用该镜像下载的文件，返回其路径
+//   - 与主镜像得到相同的哈希: 多个镜像一致而与 SHASUMS256.txt 不同，说明校验和文件本身可疑，失败
+//   - 与主镜像、SHASUMS256.txt 都不同: 镜像之间不一致，失败
+// 备用镜像下载失败时尝试下一个，全部失败时返回原来的不匹配错误
+func crossCheckMirrors(ctx context.Context, res *targetResult, mm *checksumMismatch) (string, error) {
+    for i, base := range opts.CrossCheckMirrors {
+        url := (&distSource{Base: strings.TrimRight(base, "/")}).ArchiveURL(res.Version, res.Platform)
+        alt := tempPath(res.OutFile, res.Version, fmt.Sprintf(".alt%d.tmp", i))
+        logf(levelSummary, "\n🔀 校验[%s] 校验和不匹配，改从 %s 下载对比\n", res.Platform, url)
+
+        sum, err := downloadArchive(ctx, alt, url, res.Platform)
+        if err != nil {
+            os.Remove(alt)
+            logf(levelError, "⚠️  校验[%s] 备用镜像 %s 下载失败: %v\n", res.Platform, url, err)
+            continue
+        }
+        switch sum {
+        case mm.Want:
+            logf(levelSummary, "🔀 校验[%s] %s 与 SHASUMS256.txt 一致，主镜像的 %s 有误 (sha256 %s)，改用该镜像的文件\n",
+                res.Platform, base, mm.Name, mm.Got)
+            res.URL, res.SourceSHA256 = url, sum
+            return alt, nil
+        case mm.Got:
+            os.Remove(alt)
+            err = fmt.Errorf("%s: 主镜像与 %s 得到相同的文件 (sha256 %s)，但 SHASUMS256.txt 为 %s，请核实上游发布和校验和文件是否被篡改",
+                mm.Name, base, mm.Got, mm.Want)
+        default:
+            os.Remove(alt)
+            err = fmt.Errorf("%s: 镜像之间不一致，主镜像 %s，%s 为 %s，SHASUMS256.txt 为 %s",
+                mm.Name, mm.Got, base, sum, mm.Want)
+        }
+        logf(levelError, "🚨 校验[%s] %v\n", res.Platform, err)
+        return "", err
+    }
+    return "", mm
+}
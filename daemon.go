@@ -0,0 +1,131 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net"
+    "net/http"
+    "os"
+    "os/signal"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// 最近一轮的结果，供 /status 查询
+type runStatus struct {
+    mu       sync.Mutex
+    Running  bool      `json:"running"`
+    Cycles   int       `json:"cycles"`
+    Version  string    `json:"version,omitempty"`
+    Time     time.Time `json:"time"`
+    Success  bool      `json:"success"`
+    ExitCode int       `json:"exit_code"`
+    Error    string    `json:"error,omitempty"`
+}
+
+var status = &runStatus{}
+
+func (s *runStatus) begin() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.Running = true
+}
+
+func (s *runStatus) record(version string, code int, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.Running = false
+    s.Cycles++
+    s.Version, s.Time, s.ExitCode = version, time.Now(), code
+    s.Success = err == nil && code == exitOK
+    s.Error = ""
+    if err != nil {
+        s.Error = err.Error()
+    }
+}
+
+func (s *runStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(s)
+}
+
+// 启动 -health-addr: /healthz 进程存活即返回 200，/status 返回最近一轮结果
+// 监听失败同步返回，便于启动时直接报错
+func startHealthServer(addr string) error {
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte("ok\n"))
+    })
+    mux.Handle("/status", status)
+    srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+    go func() {
+        if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            logf(levelError, "⚠️  健康检查服务退出: %v\n", err)
+        }
+    }()
+    logf(levelSummary, "🩺 健康检查: http://%s/healthz /status\n", ln.Addr())
+    return nil
+}
+
+// 常驻模式下最近一次全部成功的版本，版本未变化时跳过整轮
+var lastBuiltVersion string
+
+// -interval: 按固定间隔反复执行完整流程，单轮失败只记录不退出
+// 收到 SIGINT/SIGTERM 后不打断当前一轮，在本轮结束后退出；再次收到信号则立即终止
+func runDaemon(ctx context.Context, updateCh <-chan string) {
+    if opts.HealthAddr != "" {
+        if err := startHealthServer(opts.HealthAddr); err != nil {
+            fatal(err)
+        }
+    }
+
+    stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    go func() {
+        <-stopCtx.Done()
+        // 恢复默认处理，第二次信号直接结束进程
+        stop()
+        logf(levelSummary, "\n⛔ 收到退出信号，本轮结束后退出\n")
+    }()
+
+    for cycle := 1; ; cycle++ {
+        started := time.Now()
+        status.begin()
+        failureCount.Store(0)
+        resetMetadataCache()
+        reportRuns = nil
+        sriResults = nil
+        resetSpeedSamples()
+        version, code, err := runPipeline(ctx)
+        status.record(version, code, err)
+        if err != nil {
+            logf(levelError, "❌ 第 %d 轮失败: %v\n", cycle, err)
+        } else {
+            if code == exitOK {
+                lastBuiltVersion = version
+            }
+            logf(levelSummary, "🔁 第 %d 轮结束: 版本 %s，退出码 %d，耗时 %s\n",
+                cycle, version, code, time.Since(started).Round(time.Second))
+        }
+        if cycle == 1 {
+            reportUpdate(updateCh)
+        }
+
+        if stopCtx.Err() != nil {
+            return
+        }
+        logf(levelSummary, "⏳ %s 后开始下一轮\n", opts.Interval)
+        select {
+        case <-stopCtx.Done():
+            return
+        case <-time.After(opts.Interval):
+        }
+    }
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+)
+
+// 空间不足时的轮询间隔
+const spacePollInterval = 10 * time.Second
+
+// 本轮预计创建的文件数 (临时压缩包、输出及其附属文件)，由 runPipeline 按目标数设置
+var expectedFiles int64
+
+// 单个目标最多同时占用的文件数: 临时压缩包和输出，加上按参数产生的附属文件
+func filesPerTarget() int64 {
+    n := int64(2)
+    for _, on := range []bool{opts.AlsoGzip, opts.SidecarMeta, opts.Provenance} {
+        if on {
+            n++
+        }
+    }
+    return n + int64(len(opts.Extra))
+}
+
+// 下载前检查 -tmp-dir 和 -out 的可用空间，低于 -min-free-space 或剩余 inode 不够本轮预计的文件数时
+// 按 -space-wait 等待其他进程清理后再继续，等待超时或未设置 -space-wait 时失败
+// overlay 等容器文件系统上 inode 可能先于字节耗尽，此时写文件同样报 ENOSPC，因此 inode 默认总是检查，
+// 只有 -skip-space-check 关闭整个检查
+func waitForSpace(ctx context.Context, platform string) error {
+    if opts.SkipSpaceCheck {
+        return nil
+    }
+    dirs := []string{opts.Out}
+    if opts.TmpDir != "" && opts.TmpDir != opts.Out {
+        dirs = append(dirs, opts.TmpDir)
+    }
+
+    start := time.Now()
+    waiting := false
+    for {
+        dir, free, err := lowestFreeSpace(dirs)
+        if err != nil {
+            return err
+        }
+        inodeDir, inodes, err := lowestFreeInodes(dirs)
+        if err != nil {
+            return err
+        }
+        bytesOK := free < 0 || free >= opts.MinFreeSpace
+        inodesOK := inodes < 0 || inodes >= expectedFiles
+        if bytesOK && inodesOK {
+            if waiting {
+                logf(levelSummary, "\n💾 空间[%s] 已恢复 (%s，%s)，等待了 %s\n",
+                    platform, spaceHeadroom(dir, free), inodeHeadroom(inodeDir, inodes), time.Since(start).Round(time.Second))
+            } else {
+                logf(levelPhase, "\n💾 空间[%s] %s，%s\n", platform, spaceHeadroom(dir, free), inodeHeadroom(inodeDir, inodes))
+            }
+            return nil
+        }
+        var short string
+        if !bytesOK {
+            short = fmt.Sprintf("%s 可用空间 %s 低于 -min-free-space %s", dir, formatBytes(free), formatBytes(opts.MinFreeSpace))
+        } else {
+            short = fmt.Sprintf("%s 剩余 inode %d 少于本轮预计的 %d 个文件", inodeDir, inodes, expectedFiles)
+        }
+        waited := time.Since(start)
+        if waited >= opts.SpaceWait {
+            return errors.New(short)
+        }
+        logf(levelSummary, "\n💾 空间[%s] %s，等待释放 (已等待 %s，最多 %s)\n",
+            platform, short, waited.Round(time.Second), opts.SpaceWait)
+        waiting = true
+        select {
+        case <-time.After(min(spacePollInterval, opts.SpaceWait-waited)):
+        case <-ctx.Done():
+            return context.Cause(ctx)
+        }
+    }
+}
+
+func spaceHeadroom(dir string, free int64) string {
+    if free < 0 {
+        return "可用空间未知"
+    }
+    return fmt.Sprintf("%s 可用 %s", dir, formatBytes(free))
+}
+
+func inodeHeadroom(dir string, inodes int64) string {
+    if inodes < 0 {
+        return "inode 不限"
+    }
+    return fmt.Sprintf("%s 剩余 inode %d (预计需要 %d)", dir, inodes, expectedFiles)
+}
+
+// 返回剩余 inode 最少的目录及其剩余数，所有目录都不限制时为 -1
+func lowestFreeInodes(dirs []string) (string, int64, error) {
+    var lowDir string
+    var low int64 = -1
+    for _, dir := range dirs {
+        n, err := freeInodes(dir)
+        if err != nil {
+            return "", 0, fmt.Errorf("获取 %s 剩余 inode 失败: %w", dir, err)
+        }
+        if n >= 0 && (low < 0 || n < low) {
+            lowDir, low = dir, n
+        }
+    }
+    return lowDir, low, nil
+}
+
+// 返回可用空间最少的目录及其可用字节数，所有目录都无法获取时为 -1
+func lowestFreeSpace(dirs []string) (string, int64, error) {
+    var lowDir string
+    var low int64 = -1
+    for _, dir := range dirs {
+        free, err := freeSpace(dir)
+        if err != nil {
+            return "", 0, fmt.Errorf("获取 %s 可用空间失败: %w", dir, err)
+        }
+        if free >= 0 && (low < 0 || free < low) {
+            lowDir, low = dir, free
+        }
+    }
+    return lowDir, low, nil
+}
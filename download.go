@@ -0,0 +1,170 @@
+package main
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// 断点续传所需的状态，在同一目标的多次重试之间共享
+type resumeState struct {
+    validator string // 首次响应的强 ETag 或 Last-Modified，续传时作为 If-Range
+    size      int64  // 最近一次响应给出的完整文件大小 (续传时含已下载的部分)，未知时为 -1
+}
+
+// 记录响应的实体校验值，只有拿到校验值后已下载的部分才可信
+func (rs *resumeState) remember(resp *http.Response) {
+    if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+        rs.validator = etag
+    } else {
+        rs.validator = resp.Header.Get("Last-Modified")
+    }
+}
+
+// 下载到 filename，needSourceHash 开启时边下载边计算 SHA-256 并返回十六进制摘要
+// rs 非空且上次尝试已记录校验值时，用 Range + If-Range 续传；
+// 服务器返回 200 说明文件已变化或不支持续传，此时从头下载
+// -segments 大于 1 时先尝试分段下载，服务器不支持时退回单连接
+func downloadFile(ctx context.Context, filename, url, platform string, rs *resumeState) (string, error) {
+    var offset int64
+    if rs != nil && rs.validator != "" {
+        if fi, err := os.Stat(filename); err == nil {
+            offset = fi.Size()
+        }
+    }
+    if opts.Segments > 1 && offset == 0 {
+        sum, err := downloadSegmented(ctx, filename, url, platform)
+        switch {
+        case errors.Is(err, errNoRanges):
+            logf(levelPhase, "\n⚠️  %s: %v，改用单连接下载\n", platform, err)
+        case !errors.Is(err, errSegmentsTooSmall):
+            return sum, err
+        }
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return "", err
+    }
+    if offset > 0 {
+        req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+        req.Header.Set("If-Range", rs.validator)
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        offset = 0
+    case http.StatusPartialContent:
+        if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+            return "", fmt.Errorf("续传响应的 Content-Range 与本地进度不一致: %q", resp.Header.Get("Content-Range"))
+        }
+    default:
+        return "", newHTTPStatusError(resp)
+    }
+    if rs != nil && offset == 0 {
+        rs.remember(resp)
+    }
+
+    flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+    if offset > 0 {
+        flags = os.O_WRONLY | os.O_APPEND
+    }
+    out, err := os.OpenFile(filename, flags, 0o644)
+    if err != nil {
+        return "", err
+    }
+    defer out.Close()
+
+    var h hash.Hash
+    if needSourceHash {
+        h = sha256.New()
+        // 续传时先把已下载的部分计入哈希
+        if offset > 0 {
+            if err := hashPrefix(h, filename, offset); err != nil {
+                return "", err
+            }
+        }
+    }
+
+    total := resp.ContentLength
+    if total >= 0 {
+        total += offset
+    }
+    if rs != nil {
+        rs.size = total
+    }
+    pw := &ProgressWriter{Phase: PhaseDownload, Subject: platform, Total: total, Written: offset, Samples: speedRecorder(url), Stall: stallCounter(ctx)}
+    if offset > 0 {
+        logf(levelPhase, "\n↪️  续传[%s] 从 %d 字节处继续\n", platform, offset)
+    }
+
+    var w io.Writer = pw
+    if h != nil {
+        w = io.MultiWriter(pw, h)
+    }
+    _, err = io.Copy(out, io.TeeReader(resp.Body, w))
+    pw.Done()
+    if err != nil || h == nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 解析 "bytes START-END/TOTAL" 中的 START
+func contentRangeStart(v string) (int64, bool) {
+    var start, end int64
+    var total string
+    if _, err := fmt.Sscanf(v, "bytes %d-%d/%s", &start, &end, &total); err != nil {
+        return 0, false
+    }
+    return start, true
+}
+
+func hashPrefix(h hash.Hash, filename string, n int64) error {
+    f, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    _, err = io.CopyN(h, f, n)
+    return err
+}
+
+// 下载单个文件: 与目标的主下载一样占用下载名额，按 -retries 重试，每次尝试受目标超时限制
+func downloadArchive(ctx context.Context, filename, url, platform string) (string, error) {
+    slot, err := acquireDownload(ctx)
+    if err != nil {
+        return "", err
+    }
+    defer slot.release()
+
+    var sum string
+    rs := &resumeState{}
+    err = withRetry(ctx, platform, func() error {
+        attemptCtx := ctx
+        if t := targetTimeout(platform); t > 0 {
+            var cancel context.CancelFunc
+            attemptCtx, cancel = context.WithTimeout(ctx, t)
+            defer cancel()
+        }
+        attemptCtx, stopStall := watchStall(attemptCtx)
+        var err error
+        sum, err = downloadFile(attemptCtx, filename, url, platform, rs)
+        err = stopStall(err)
+        slot.report(err)
+        return err
+    })
+    return sum, err
+}
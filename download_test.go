@@ -0,0 +1,140 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+)
+
+// 第一次请求只发出一半内容就断开连接，之后的请求按 Range/If-Range 正常响应
+// etag 返回第 n 次请求使用的 ETag，用来模拟重试期间文件发生变化
+type droppingServer struct {
+    data []byte
+    etag func(n int) string
+
+    mu       sync.Mutex
+    requests []*http.Request
+}
+
+func (s *droppingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    s.requests = append(s.requests, r)
+    n := len(s.requests)
+    s.mu.Unlock()
+
+    w.Header().Set("ETag", s.etag(n))
+    if n == 1 {
+        w.Header().Set("Content-Length", strconv.Itoa(len(s.data)))
+        w.WriteHeader(http.StatusOK)
+        w.Write(s.data[:len(s.data)/2])
+        w.(http.Flusher).Flush()
+        conn, _, err := w.(http.Hijacker).Hijack()
+        if err == nil {
+            conn.Close()
+        }
+        return
+    }
+    http.ServeContent(w, r, "node.tar.xz", time.Time{}, bytes.NewReader(s.data))
+}
+
+func setupDownloadTest(t *testing.T) {
+    t.Helper()
+    saved, savedHash, savedClient := opts, needSourceHash, httpClient
+    t.Cleanup(func() { opts, needSourceHash, httpClient = saved, savedHash, savedClient; initRetryLimiter() })
+    opts.Retries = 2
+    opts.RetryRate = 0
+    needSourceHash = true
+    httpClient = &http.Client{Transport: &http.Transport{}}
+    initRetryLimiter()
+}
+
+// 与主下载相同的重试方式: 多次尝试共享同一个 resumeState
+func downloadWithRetry(t *testing.T, url string) (string, []byte, *resumeState) {
+    t.Helper()
+    filename := filepath.Join(t.TempDir(), "node.tar.xz.tmp")
+    rs := &resumeState{}
+    var sum string
+    err := withRetry(context.Background(), "linux-x64", func() error {
+        var err error
+        sum, err = downloadFile(context.Background(), filename, url, "linux-x64", rs)
+        return err
+    })
+    if err != nil {
+        t.Fatalf("下载失败: %v", err)
+    }
+    got, err := os.ReadFile(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return sum, got, rs
+}
+
+func testPayload() []byte {
+    var buf bytes.Buffer
+    for i := 0; buf.Len() < 1<<20; i++ {
+        fmt.Fprintf(&buf, "%08d", i)
+    }
+    return buf.Bytes()
+}
+
+func TestDownloadResumesAfterDroppedConnection(t *testing.T) {
+    setupDownloadTest(t)
+    data := testPayload()
+    s := &droppingServer{data: data, etag: func(int) string { return `"v1"` }}
+    srv := httptest.NewServer(s)
+    defer srv.Close()
+
+    sum, got, rs := downloadWithRetry(t, srv.URL+"/node.tar.xz")
+    if !bytes.Equal(got, data) {
+        t.Fatalf("续传结果与原文件不一致: %d 字节，期望 %d", len(got), len(data))
+    }
+    want := sha256.Sum256(data)
+    if sum != hex.EncodeToString(want[:]) {
+        t.Fatalf("续传后的 SHA-256 应覆盖完整文件: %s", sum)
+    }
+    if rs.size != int64(len(data)) {
+        t.Fatalf("续传时的进度总量应为完整文件大小 %d，实际 %d", len(data), rs.size)
+    }
+
+    if len(s.requests) != 2 {
+        t.Fatalf("期望 2 次请求，实际 %d", len(s.requests))
+    }
+    retry := s.requests[1]
+    var start int64
+    if _, err := fmt.Sscanf(retry.Header.Get("Range"), "bytes=%d-", &start); err != nil || start <= 0 || start > int64(len(data)/2) {
+        t.Fatalf("重试应从已下载的位置续传，Range: %q", retry.Header.Get("Range"))
+    }
+    if retry.Header.Get("If-Range") != `"v1"` {
+        t.Fatalf("续传应带上首次响应的 ETag，If-Range: %q", retry.Header.Get("If-Range"))
+    }
+}
+
+// 重试期间文件已变化 (ETag 不同)，服务器忽略 Range 返回整个文件，应从头下载而不是拼接新旧内容
+func TestDownloadRestartsWhenFileChanged(t *testing.T) {
+    setupDownloadTest(t)
+    data := testPayload()
+    s := &droppingServer{data: data, etag: func(n int) string { return fmt.Sprintf(`"v%d"`, n) }}
+    srv := httptest.NewServer(s)
+    defer srv.Close()
+
+    _, got, rs := downloadWithRetry(t, srv.URL+"/node.tar.xz")
+    if !bytes.Equal(got, data) {
+        t.Fatalf("重新下载的结果与原文件不一致: %d 字节，期望 %d", len(got), len(data))
+    }
+    if rs.size != int64(len(data)) {
+        t.Fatalf("进度总量应为完整文件大小 %d，实际 %d", len(data), rs.size)
+    }
+    if s.requests[1].Header.Get("Range") == "" {
+        t.Fatalf("重试仍应先尝试续传")
+    }
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "sync"
+    "time"
+)
+
+// 测速时下载的字节数
+const speedSampleBytes = 8 << 20
+
+// -dry-run 计划中的一项: 一个版本的一个平台
+type planEntry struct {
+    Version  string
+    Platform string
+    URL      string
+    Size     int64 // Content-Length，未知时为 -1
+    Err      error
+}
+
+// -dry-run: 对每个版本 × 平台发 HEAD 请求汇总下载量，按实测或 -assume-speed 给出的速度估算耗时
+// 不下载压缩包、不写任何输出，便于在按流量计费的线路上决定是否拆分批量任务
+func runDryRun(ctx context.Context) error {
+    versions, err := resolveVersions(ctx)
+    if err != nil {
+        return err
+    }
+    if len(versions) == 0 {
+        version, err := resolveVersion(ctx)
+        if err != nil {
+            return err
+        }
+        versions = []string{version}
+    }
+    selected, err := selectTargets()
+    if err != nil {
+        return err
+    }
+    platforms := make([]string, 0, len(selected))
+    for _, platform := range selected {
+        platforms = append(platforms, platform)
+    }
+    sort.Strings(platforms)
+
+    plan := make([]*planEntry, 0, len(versions)*len(platforms))
+    for _, version := range versions {
+        for _, platform := range platforms {
+            plan = append(plan, &planEntry{Version: version, Platform: platform, URL: buildURL(version, platform), Size: -1})
+        }
+    }
+    probePlan(ctx, plan)
+
+    var total int64
+    var found, missing, unknown int
+    for i, version := range versions {
+        logf(levelSummary, "\n📦 %s\n", version)
+        var sub int64
+        for _, e := range plan[i*len(platforms) : (i+1)*len(platforms)] {
+            switch {
+            case e.Err != nil:
+                missing++
+                logf(levelSummary, "   %-14s ❌ %v\n", e.Platform, e.Err)
+            case e.Size < 0:
+                found++
+                unknown++
+                logf(levelSummary, "   %-14s 大小未知\n", e.Platform)
+            default:
+                found++
+                sub += e.Size
+                logf(levelSummary, "   %-14s %s\n", e.Platform, formatBytes(e.Size))
+            }
+        }
+        logf(levelSummary, "   小计 %s\n", formatBytes(sub))
+        total += sub
+    }
+
+    logf(levelSummary, "\n📊 共 %d 个版本，%d 个压缩包，预计下载 %s\n", len(versions), found, formatBytes(total))
+    if unknown > 0 {
+        logf(levelSummary, "⚠️  %d 个压缩包未返回 Content-Length，未计入总量\n", unknown)
+    }
+    if missing > 0 {
+        logf(levelSummary, "⚠️  %d 个压缩包不可用，实际运行时会失败\n", missing)
+    }
+    if total == 0 {
+        return nil
+    }
+
+    speed, how := float64(opts.AssumeSpeed), "-assume-speed"
+    if speed <= 0 {
+        url := ""
+        for _, e := range plan {
+            if e.Err == nil && e.Size > 0 {
+                url = e.URL
+                break
+            }
+        }
+        if speed, err = measureSpeed(ctx, url); err != nil {
+            logf(levelError, "⚠️  测速失败: %v，可用 -assume-speed 指定速度\n", err)
+            return nil
+        }
+        how = "实测单连接"
+    }
+    eta := time.Duration(float64(total) / speed * float64(time.Second))
+    logf(levelSummary, "⏱️  按 %s/s (%s) 估算，下载约需 %s，不含解压和压缩\n",
+        formatBytes(int64(speed)), how, eta.Round(time.Second))
+    return nil
+}
+
+// 并发对计划中的每一项发 HEAD 请求，并发数与下载相同
+func probePlan(ctx context.Context, plan []*planEntry) {
+    sem := make(chan struct{}, opts.DownloadConcurrency)
+    var wg sync.WaitGroup
+    for _, e := range plan {
+        wg.Add(1)
+        go func(e *planEntry) {
+            defer wg.Done()
+            release, err := acquire(ctx, sem)
+            if err != nil {
+                e.Err = err
+                return
+            }
+            defer release()
+            e.Err = withRetry(ctx, e.Platform, func() error {
+                var err error
+                e.Size, err = headSize(ctx, e.URL)
+                return err
+            })
+        }(e)
+    }
+    wg.Wait()
+}
+
+// 返回 url 的 Content-Length，服务器未给出时为 -1
+func headSize(ctx context.Context, url string) (int64, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+    if err != nil {
+        return -1, err
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return -1, err
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return -1, newHTTPStatusError(resp)
+    }
+    return resp.ContentLength, nil
+}
+
+// 下载 url 开头的一段估算单连接速度 (字节/秒)
+func measureSpeed(ctx context.Context, url string) (float64, error) {
+    if url == "" {
+        return 0, errors.New("没有可用于测速的压缩包")
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", speedSampleBytes-1))
+    started := time.Now()
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+        return 0, newHTTPStatusError(resp)
+    }
+    n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, speedSampleBytes))
+    if err != nil {
+        return 0, err
+    }
+    elapsed := time.Since(started).Seconds()
+    if n == 0 || elapsed <= 0 {
+        return 0, errors.New("测速样本为空")
+    }
+    return float64(n) / elapsed, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+)
+
+// -dump-urls: 解析版本后输出所有压缩包地址和 SHASUMS256.txt 地址，不下载
+// 便于交给 aria2c 等外部下载器，下载完成后再用 -source-dir 构建
+func dumpURLs(ctx context.Context) error {
+    version, err := resolveVersion(ctx)
+    if err != nil {
+        return err
+    }
+    selected, err := selectTargets()
+    if err != nil {
+        return err
+    }
+    urls := make(map[string]string, len(selected))
+    platforms := make([]string, 0, len(selected))
+    for _, platform := range selected {
+        urls[platform] = buildURL(version, platform)
+        platforms = append(platforms, platform)
+    }
+    sort.Strings(platforms)
+
+    if opts.DumpFormat == "json" {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        return enc.Encode(struct {
+            Version string            `json:"version"`
+            Shasums string            `json:"shasums"`
+            URLs    map[string]string `json:"urls"`
+        }{version, releaseSource.ChecksumURL(version), urls})
+    }
+    for _, platform := range platforms {
+        fmt.Println(urls[platform])
+    }
+    fmt.Println(releaseSource.ChecksumURL(version))
+    return nil
+}
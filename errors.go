@@ -0,0 +1,47 @@
+package main
+
+import "fmt"
+
+// 单个目标在各阶段失败时返回的错误类型，都带有平台、来源地址和底层原因
+// 调用方可用 errors.As 区分失败阶段 (如下载错误可重试、校验错误需要告警)，
+// errors.Is 仍能穿过它们匹配底层错误 (如 context.Canceled)
+
+// 下载源压缩包或 SHASUMS256.txt 失败
+type DownloadError struct {
+    Platform string
+    URL      string
+    Err      error
+}
+
+func (e *DownloadError) Error() string { return fmt.Sprintf("下载[%s] %v", e.Platform, e.Err) }
+func (e *DownloadError) Unwrap() error { return e.Err }
+
+// 源压缩包与 SHASUMS256.txt 不符，或按 -checksum-mode required 缺少条目
+type ChecksumError struct {
+    Platform string
+    URL      string
+    Err      error
+}
+
+func (e *ChecksumError) Error() string { return fmt.Sprintf("校验[%s] %v", e.Platform, e.Err) }
+func (e *ChecksumError) Unwrap() error { return e.Err }
+
+// 解压失败、找不到 node 可执行文件或架构不符
+type ExtractError struct {
+    Platform string
+    URL      string
+    Err      error
+}
+
+func (e *ExtractError) Error() string { return fmt.Sprintf("解压[%s] %v", e.Platform, e.Err) }
+func (e *ExtractError) Unwrap() error { return e.Err }
+
+// 压缩或写出输出文件失败
+type CompressError struct {
+    Platform string
+    URL      string
+    Err      error
+}
+
+func (e *CompressError) Error() string { return fmt.Sprintf("压缩[%s] %v", e.Platform, e.Err) }
+func (e *CompressError) Unwrap() error { return e.Err }
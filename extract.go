@@ -0,0 +1,292 @@
+package main
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "path"
+    "regexp"
+    "strings"
+
+    "github.com/ulikunitz/xz"
+)
+
+// 压缩包格式，决定 ExtractNode 如何解开输入流
+type ArchiveFormat int
+
+const (
+    ArchiveTarXZ ArchiveFormat = iota
+    ArchiveTarGZ
+    ArchiveTar
+    ArchiveZip // 需要随机访问，使用 ExtractNodeZip
+)
+
+func (f ArchiveFormat) String() string {
+    switch f {
+    case ArchiveTarXZ:
+        return "tar.xz"
+    case ArchiveTarGZ:
+        return "tar.gz"
+    case ArchiveTar:
+        return "tar"
+    case ArchiveZip:
+        return "zip"
+    }
+    return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+}
+
+// ExtractNode 和 ExtractNodeZip 的设置，零值即安全的默认值:
+// 单个成员最多解压 1GB，按 /bin/node 后缀 (zip 中为 node.exe) 匹配，不尝试恢复损坏的 zip
+type ExtractOptions struct {
+    MaxSize        int64          // 单个成员解压后的上限，0 使用默认的 1GB，负数表示不限制
+    NodePath       *regexp.Regexp // 匹配 tar 中 node 可执行文件的路径，nil 时按 /bin/node 后缀匹配
+    NodeByBasename bool           // 在任意目录中查找文件名为 node/node.exe 的普通文件，恰好一个时才使用
+    ZipRecover     bool           // zip 中央目录损坏时扫描本地文件头尽力恢复 node.exe
+}
+
+// 实际生效的单个成员上限，0 表示不限制
+func (o ExtractOptions) maxSize() int64 {
+    switch {
+    case o.MaxSize == 0:
+        return defaultMaxExtractSize
+    case o.MaxSize < 0:
+        return 0
+    }
+    return o.MaxSize
+}
+
+var (
+    errNoNodeMember    = errors.New("未找到 bin/node")
+    errNoNodeExe       = errors.New("未找到 node.exe")
+    errUnsafeMember    = errors.New("压缩包成员路径不安全")
+    errExtractTooLarge = errors.New("超过 -max-extract-size")
+)
+
+// 成员路径是否会逃出解压目录: 绝对路径、盘符、含 .. 的路径 (zip-slip)
+// 我们只按名字挑出已知成员，但这样的压缩包本身就不可信，直接拒绝
+func unsafeMemberName(name string) bool {
+    name = strings.ReplaceAll(name, "\\", "/")
+    if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+        return true
+    }
+    for _, part := range strings.Split(name, "/") {
+        if part == ".." {
+            return true
+        }
+    }
+    return false
+}
+
+// 检查成员路径，以及符号链接的目标是否指向包外
+func checkMember(name, linkname string) error {
+    if unsafeMemberName(name) {
+        return fmt.Errorf("%w: %q", errUnsafeMember, name)
+    }
+    if linkname == "" {
+        return nil
+    }
+    target := path.Join(path.Dir(strings.ReplaceAll(name, "\\", "/")), linkname)
+    if strings.HasPrefix(linkname, "/") || target == ".." || strings.HasPrefix(target, "../") {
+        return fmt.Errorf("%w: %q -> %q", errUnsafeMember, name, linkname)
+    }
+    return nil
+}
+
+// 按 MaxSize 限制单个成员: 声明的大小超出时直接拒绝，
+// 否则返回的 reader 在实际解压出的字节数超出时报错，防止声明大小与内容不符的解压炸弹
+func (o ExtractOptions) limitMember(name string, declared int64, r io.Reader) (io.Reader, error) {
+    limit := o.maxSize()
+    if limit <= 0 {
+        return r, nil
+    }
+    if declared > limit {
+        return nil, fmt.Errorf("%s 声明大小 %s %w (%s)", name, formatBytes(declared), errExtractTooLarge, formatBytes(limit))
+    }
+    return &sizeLimitReader{r: r, name: name, max: limit}, nil
+}
+
+// zip 目录中记录的解压后大小，超出 int64 时按最大值算
+func zipDeclaredSize(f *zip.File) int64 {
+    if f.UncompressedSize64 > math.MaxInt64 {
+        return math.MaxInt64
+    }
+    return int64(f.UncompressedSize64)
+}
+
+type sizeLimitReader struct {
+    r    io.Reader
+    name string
+    n    int64
+    max  int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+    n, err := l.r.Read(p)
+    l.n += int64(n)
+    if l.n > l.max {
+        return n, fmt.Errorf("%s 解压出的内容 %w (%s)", l.name, errExtractTooLarge, formatBytes(l.max))
+    }
+    return n, err
+}
+
+// 按格式解开 tar 外层的压缩，返回未压缩的 tar 流
+func tarStream(r io.Reader, format ArchiveFormat) (io.Reader, error) {
+    switch format {
+    case ArchiveTarXZ:
+        return xz.NewReader(r)
+    case ArchiveTarGZ:
+        // gzip.Reader 的 Close 不关闭底层流，读完即可丢弃
+        return gzip.NewReader(r)
+    case ArchiveTar:
+        return r, nil
+    case ArchiveZip:
+        return nil, errors.New("zip 需要随机访问，请使用 ExtractNodeZip")
+    }
+    return nil, fmt.Errorf("未知的压缩包格式: %v", format)
+}
+
+// 从 tar 流中提取 node 可执行文件写入 w，不涉及文件、网络和命令行参数
+// 成员按 o.NodePath (默认 /bin/node 后缀) 匹配；zip 需要随机访问，请用 ExtractNodeZip
+func ExtractNode(r io.Reader, format ArchiveFormat, w io.Writer, o ExtractOptions) error {
+    r, err := tarStream(r, format)
+    if err != nil {
+        return err
+    }
+
+    tr := tar.NewReader(r)
+    h, err := o.nextNodeMember(tr)
+    if err != nil {
+        return err
+    }
+    mr, err := o.limitMember(h.Name, h.Size, tr)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(w, mr); err != nil {
+        return err
+    }
+    return o.ensureSingleNodeMember(tr, h.Name)
+}
+
+// 从 zip 中提取 node.exe 写入 w
+// 中央目录损坏时，开启 o.ZipRecover 则改为扫描本地文件头尽力恢复
+func ExtractNodeZip(ra io.ReaderAt, size int64, w io.Writer, o ExtractOptions) error {
+    zr, err := zip.NewReader(ra, size)
+    if err != nil {
+        if !o.ZipRecover {
+            return err
+        }
+        logf(levelError, "\n⚠️  zip 中央目录无法读取 (%v)，-zip-recover: 扫描本地文件头恢复 node.exe，建议之后重新下载该压缩包\n", err)
+        return o.recoverNodeFromZip(ra, size, w)
+    }
+    for _, f := range zr.File {
+        if err := checkMember(f.Name, ""); err != nil {
+            return err
+        }
+    }
+    var candidates []*zip.File
+    for _, f := range zr.File {
+        if o.isNodeZipMember(f) {
+            candidates = append(candidates, f)
+        }
+    }
+    if o.NodeByBasename {
+        if err := singleCandidate(len(candidates), errNoNodeExe, func(i int) string { return candidates[i].Name }); err != nil {
+            return err
+        }
+    }
+    for _, f := range candidates {
+        rc, err := f.Open()
+        if err != nil {
+            return err
+        }
+        defer rc.Close()
+        mr, err := o.limitMember(f.Name, zipDeclaredSize(f), rc)
+        if err != nil {
+            return err
+        }
+        _, err = io.Copy(w, mr)
+        return err
+    }
+    return errNoNodeExe
+}
+
+// 把 tr 前进到 node 可执行文件成员并返回其头部，读完仍未找到时返回 errNoNodeMember
+// 途经的成员路径不安全时直接返回 errUnsafeMember
+func (o ExtractOptions) nextNodeMember(tr *tar.Reader) (*tar.Header, error) {
+    for {
+        h, err := tr.Next()
+        if err == io.EOF {
+            return nil, errNoNodeMember
+        }
+        if err != nil {
+            return nil, err
+        }
+        if err := checkMember(h.Name, ""); err != nil {
+            return nil, err
+        }
+        if h.Typeflag == tar.TypeReg && o.isNodeTarMember(h.Name) {
+            return h, nil
+        }
+    }
+}
+
+// 判断 tar 成员是否为 node 可执行文件
+func (o ExtractOptions) isNodeTarMember(name string) bool {
+    if o.NodeByBasename {
+        return path.Base(name) == "node"
+    }
+    if o.NodePath != nil {
+        return o.NodePath.MatchString(name)
+    }
+    return strings.HasSuffix(name, "/bin/node")
+}
+
+// NodeByBasename: 在任意目录中查找文件名为 node / node.exe 的普通文件，恰好一个时才使用，
+// 不依赖 bin/node 这样的固定布局；默认仍按 NodePath 或 /bin/node 后缀严格匹配
+func (o ExtractOptions) isNodeZipMember(f *zip.File) bool {
+    if o.NodeByBasename {
+        return !f.FileInfo().IsDir() && path.Base(strings.ReplaceAll(f.Name, "\\", "/")) == "node.exe"
+    }
+    return strings.HasSuffix(f.Name, "node.exe")
+}
+
+// tar 只能顺序读取，第一个候选写出之后继续读完其余成员，确认没有第二个候选
+// 未开启 NodeByBasename 时直接返回
+func (o ExtractOptions) ensureSingleNodeMember(tr *tar.Reader, chosen string) error {
+    if !o.NodeByBasename {
+        return nil
+    }
+    names := []string{chosen}
+    for {
+        h, err := o.nextNodeMember(tr)
+        if errors.Is(err, errNoNodeMember) {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        names = append(names, h.Name)
+    }
+    return singleCandidate(len(names), errNoNodeMember, func(i int) string { return names[i] })
+}
+
+// 候选恰好一个时记录选中的路径，否则报错并列出全部候选
+func singleCandidate(n int, none error, name func(int) string) error {
+    switch n {
+    case 0:
+        return none
+    case 1:
+        logf(levelPhase, "\n🔎 -node-by-basename 选中 %s\n", name(0))
+        return nil
+    }
+    all := make([]string, n)
+    for i := range all {
+        all[i] = name(i)
+    }
+    return fmt.Errorf("-node-by-basename 找到 %d 个候选，无法确定使用哪个: %s", n, strings.Join(all, ", "))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "testing"
+)
+
+// 模糊测试时的单个成员上限，远小于默认值，便于发现超出限制仍继续写出的情况
+const fuzzMaxExtract = 1 << 20
+
+// 统计写入字节数，超出上限时报错，避免失控的输入占满内存
+type boundedWriter struct {
+    n, max int64
+}
+
+func (w *boundedWriter) Write(p []byte) (int, error) {
+    w.n += int64(len(p))
+    if w.n > w.max {
+        return 0, fmt.Errorf("写出 %d 字节，超过上限 %d", w.n, w.max)
+    }
+    return len(p), nil
+}
+
+// 超出上限时 sizeLimitReader 会连同报错交出最后一次读到的数据，
+// io.Copy 会先写出这部分，所以允许多出一个拷贝缓冲区
+func fuzzOutput() *boundedWriter {
+    return &boundedWriter{max: fuzzMaxExtract + 32<<10}
+}
+
+// 使用较小的上限和默认的成员匹配规则
+var fuzzOptions = ExtractOptions{MaxSize: fuzzMaxExtract}
+
+func FuzzExtractNodeFromTarXZ(f *testing.F) {
+    for _, platform := range []string{"linux-x64", "darwin-arm64"} {
+        data, err := fixtureTarXZ(fixtureVersion, platform)
+        if err != nil {
+            f.Fatal(err)
+        }
+        f.Add(data)
+    }
+    f.Add([]byte{})
+    f.Fuzz(func(t *testing.T, data []byte) {
+        w := fuzzOutput()
+        // 任意输入都只应返回错误而不是 panic
+        ExtractNode(bytes.NewReader(data), ArchiveTarXZ, w, fuzzOptions)
+        if w.n > w.max {
+            t.Fatalf("写出 %d 字节，超过上限 %d", w.n, int64(fuzzMaxExtract))
+        }
+    })
+}
+
+func FuzzExtractNodeFromZip(f *testing.F) {
+    data, err := fixtureZip(fixtureVersion, "win-x64")
+    if err != nil {
+        f.Fatal(err)
+    }
+    f.Add(data)
+    f.Add([]byte{})
+    f.Fuzz(func(t *testing.T, data []byte) {
+        w := fuzzOutput()
+        ExtractNodeZip(bytes.NewReader(data), int64(len(data)), w, fuzzOptions)
+        if w.n > w.max {
+            t.Fatalf("写出 %d 字节，超过上限 %d", w.n, int64(fuzzMaxExtract))
+        }
+    })
+}
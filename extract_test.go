@@ -0,0 +1,195 @@
+package main
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "errors"
+    "io"
+    "strings"
+    "testing"
+)
+
+type archiveMember struct {
+    name string
+    data []byte
+}
+
+// 按给定成员生成未压缩的 tar，全部为普通文件
+func maliciousTar(t *testing.T, members ...archiveMember) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    tw := tar.NewWriter(&buf)
+    for _, m := range members {
+        hdr := &tar.Header{Name: m.name, Mode: 0o755, Size: int64(len(m.data)), Typeflag: tar.TypeReg, ModTime: fixtureModTime}
+        if err := tw.WriteHeader(hdr); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := tw.Write(m.data); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := tw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func maliciousZip(t *testing.T, members ...archiveMember) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    zw := zip.NewWriter(&buf)
+    for _, m := range members {
+        w, err := zw.Create(m.name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, err := w.Write(m.data); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := zw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func TestExtractNodeRejectsTraversal(t *testing.T) {
+    bin := stubBinary("linux-x64")
+    cases := []struct {
+        name    string
+        members []archiveMember
+    }{
+        {"上级目录", []archiveMember{{name: "../../etc/cron.d/evil", data: []byte("x")}, {name: "node-v20.0.0-linux-x64/bin/node", data: bin}}},
+        {"node 本身越界", []archiveMember{{name: "node-v20.0.0-linux-x64/../../bin/node", data: bin}}},
+        {"绝对路径", []archiveMember{{name: "/usr/local/bin/node", data: bin}}},
+        {"反斜杠", []archiveMember{{name: `..\..\bin\node`, data: bin}, {name: "node-v20.0.0-linux-x64/bin/node", data: bin}}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            var out bytes.Buffer
+            err := ExtractNode(bytes.NewReader(maliciousTar(t, c.members...)), ArchiveTar, &out, ExtractOptions{})
+            if !errors.Is(err, errUnsafeMember) {
+                t.Fatalf("err = %v，期望 errUnsafeMember", err)
+            }
+            if out.Len() != 0 {
+                t.Fatalf("拒绝前已写出 %d 字节", out.Len())
+            }
+        })
+    }
+}
+
+func TestExtractNodeZipRejectsTraversal(t *testing.T) {
+    exe := stubBinary("win-x64")
+    for _, name := range []string{"../../node.exe", `..\..\Windows\node.exe`, "C:/Windows/node.exe", "/node.exe"} {
+        t.Run(name, func(t *testing.T) {
+            // 不安全的成员排在正常的 node.exe 之后，同样整个拒绝
+            data := maliciousZip(t, archiveMember{name: "node-v20.0.0-win-x64/node.exe", data: exe}, archiveMember{name: name, data: exe})
+            var out bytes.Buffer
+            err := ExtractNodeZip(bytes.NewReader(data), int64(len(data)), &out, ExtractOptions{})
+            if !errors.Is(err, errUnsafeMember) {
+                t.Fatalf("err = %v，期望 errUnsafeMember", err)
+            }
+            if out.Len() != 0 {
+                t.Fatalf("拒绝前已写出 %d 字节", out.Len())
+            }
+        })
+    }
+}
+
+func TestExtractNodeRejectsOversizedMember(t *testing.T) {
+    o := ExtractOptions{MaxSize: 1024}
+    big := bytes.Repeat([]byte{0}, 4096)
+
+    var out bytes.Buffer
+    err := ExtractNode(bytes.NewReader(maliciousTar(t, archiveMember{name: "node-v20.0.0-linux-x64/bin/node", data: big})), ArchiveTar, &out, o)
+    if !errors.Is(err, errExtractTooLarge) {
+        t.Fatalf("tar: err = %v，期望 errExtractTooLarge", err)
+    }
+    if out.Len() != 0 {
+        t.Fatalf("tar: 拒绝前已写出 %d 字节", out.Len())
+    }
+
+    data := maliciousZip(t, archiveMember{name: "node-v20.0.0-win-x64/node.exe", data: big})
+    out.Reset()
+    err = ExtractNodeZip(bytes.NewReader(data), int64(len(data)), &out, o)
+    if !errors.Is(err, errExtractTooLarge) {
+        t.Fatalf("zip: err = %v，期望 errExtractTooLarge", err)
+    }
+    if out.Len() != 0 {
+        t.Fatalf("zip: 拒绝前已写出 %d 字节", out.Len())
+    }
+}
+
+// 大小限制以内的成员照常解压
+func TestExtractNodeWithinLimit(t *testing.T) {
+    bin := stubBinary("linux-x64")
+    var out bytes.Buffer
+    if err := ExtractNode(bytes.NewReader(maliciousTar(t, archiveMember{name: "node-v20.0.0-linux-x64/bin/node", data: bin})), ArchiveTar, &out, ExtractOptions{MaxSize: int64(len(bin))}); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(out.Bytes(), bin) {
+        t.Fatalf("解压内容不一致")
+    }
+}
+
+// 声明大小在限制以内、实际内容超出 (声明与内容不符的解压炸弹) 时在读到超出部分时报错
+func TestLimitMemberActualSize(t *testing.T) {
+    r, err := ExtractOptions{MaxSize: 1024}.limitMember("bin/node", 10, strings.NewReader(strings.Repeat("x", 4096)))
+    if err != nil {
+        t.Fatalf("声明大小未超出却被拒绝: %v", err)
+    }
+    if _, err := io.Copy(io.Discard, r); !errors.Is(err, errExtractTooLarge) {
+        t.Fatalf("err = %v，期望 errExtractTooLarge", err)
+    }
+}
+
+// 零值使用默认上限，负数不限制；命令行的 -max-extract-size 0 对应不限制
+func TestExtractOptionsMaxSize(t *testing.T) {
+    cases := []struct {
+        max  int64
+        want int64
+    }{
+        {0, defaultMaxExtractSize},
+        {-1, 0},
+        {1024, 1024},
+    }
+    for _, c := range cases {
+        if got := (ExtractOptions{MaxSize: c.max}).maxSize(); got != c.want {
+            t.Errorf("MaxSize %d: 上限 %d，期望 %d", c.max, got, c.want)
+        }
+    }
+
+    saved := opts
+    t.Cleanup(func() { opts = saved })
+    opts.MaxExtractSize = 0
+    if got := extractOptions().maxSize(); got != 0 {
+        t.Errorf("-max-extract-size 0: 上限 %d，期望不限制", got)
+    }
+    opts.MaxExtractSize = defaultMaxExtractSize
+    if got := extractOptions().maxSize(); got != defaultMaxExtractSize {
+        t.Errorf("-max-extract-size %d: 上限 %d", int64(defaultMaxExtractSize), got)
+    }
+}
+
+func TestCheckMember(t *testing.T) {
+    cases := []struct {
+        name, linkname string
+        unsafe         bool
+    }{
+        {"node-v20.0.0-linux-x64/bin/node", "", false},
+        {"node-v20.0.0-linux-x64/bin/npm", "../lib/node_modules/npm/bin/npm-cli.js", false},
+        {"node-v20.0.0-linux-x64/../../etc/passwd", "", true},
+        {"/etc/passwd", "", true},
+        {"C:/Windows/System32/evil.dll", "", true},
+        {`..\evil`, "", true},
+        {"node-v20.0.0-linux-x64/bin/evil", "/etc/passwd", true},
+        {"node-v20.0.0-linux-x64/bin/evil", "../../../etc/passwd", true},
+    }
+    for _, c := range cases {
+        err := checkMember(c.name, c.linkname)
+        if got := errors.Is(err, errUnsafeMember); got != c.unsafe {
+            t.Errorf("checkMember(%q, %q) = %v，期望不安全 = %v", c.name, c.linkname, err, c.unsafe)
+        }
+    }
+}
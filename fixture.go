@@ -0,0 +1,183 @@
+package main
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "crypto/sha256"
+    "debug/elf"
+    "debug/macho"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "path"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/ulikunitz/xz"
+)
+
+var fixtureModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 离线夹具: 在内存中伪造一个最小的 nodejs.org/dist，
+// 包含 index.json、各平台压缩包 (内含带正确文件头的桩 node) 和 SHASUMS256.txt
+// 测试 (fixture_test.go) 和 -selftest 共用，-selftest 需要它在正式构建中可用，因此不放在 _test.go 中
+type fixture struct {
+    Version string
+    Files   map[string][]byte // 相对 dist 根目录的路径 -> 内容
+}
+
+func newFixture(version string, platforms []string) (*fixture, error) {
+    f := &fixture{Version: version, Files: map[string][]byte{}}
+
+    index, err := json.Marshal([]NodeVersion{{Version: version, LTS: "Fixture"}})
+    if err != nil {
+        return nil, err
+    }
+    f.Files["index.json"] = index
+
+    var sums []string
+    for _, platform := range platforms {
+        // 与官方 dist 相同，非 Windows 目标固定为 tar.xz，不随 -source 变化
+        var data []byte
+        ext := ".tar.xz"
+        if strings.HasPrefix(platform, "win") {
+            data, err = fixtureZip(version, platform)
+            ext = ".zip"
+        } else {
+            data, err = fixtureTarXZ(version, platform)
+        }
+        if err != nil {
+            return nil, err
+        }
+        name := "node-" + version + "-" + platform + ext
+        f.Files[path.Join(version, name)] = data
+        sum := sha256.Sum256(data)
+        sums = append(sums, hex.EncodeToString(sum[:])+"  "+name)
+    }
+    sort.Strings(sums)
+    f.Files[path.Join(version, "SHASUMS256.txt")] = []byte(strings.Join(sums, "\n") + "\n")
+    return f, nil
+}
+
+// 以 dist 根目录的布局提供夹具文件
+func (f *fixture) Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        data, ok := f.Files[strings.TrimPrefix(r.URL.Path, "/")]
+        if !ok {
+            http.NotFound(w, r)
+            return
+        }
+        http.ServeContent(w, r, path.Base(r.URL.Path), fixtureModTime, bytes.NewReader(data))
+    })
+}
+
+// 启动夹具 HTTP 服务，调用方负责 Close
+func (f *fixture) Serve() *httptest.Server {
+    return httptest.NewServer(f.Handler())
+}
+
+func fixtureTarXZ(version, platform string) ([]byte, error) {
+    var buf bytes.Buffer
+    xw, err := xz.NewWriter(&buf)
+    if err != nil {
+        return nil, err
+    }
+    tw := tar.NewWriter(xw)
+    bin := stubBinary(platform)
+    top := "node-" + version + "-" + platform + "/"
+    members := []struct {
+        name string
+        mode int64
+        data []byte
+    }{
+        {top + "bin/node", 0o755, bin},
+        {top + "LICENSE", 0o644, []byte("fixture license\n")},
+        {top + "include/node/node.h", 0o644, []byte("/* fixture */\n")},
+    }
+    for _, m := range members {
+        hdr := &tar.Header{Name: m.name, Mode: m.mode, Size: int64(len(m.data)), ModTime: fixtureModTime}
+        if err := tw.WriteHeader(hdr); err != nil {
+            return nil, err
+        }
+        if _, err := tw.Write(m.data); err != nil {
+            return nil, err
+        }
+    }
+    if err := tw.Close(); err != nil {
+        return nil, err
+    }
+    if err := xw.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func fixtureZip(version, platform string) ([]byte, error) {
+    var buf bytes.Buffer
+    zw := zip.NewWriter(&buf)
+    top := "node-" + version + "-" + platform + "/"
+    for name, data := range map[string][]byte{
+        top + "node.exe": stubBinary(platform),
+        top + "LICENSE":  []byte("fixture license\n"),
+    } {
+        w, err := zw.Create(name)
+        if err != nil {
+            return nil, err
+        }
+        if _, err := w.Write(data); err != nil {
+            return nil, err
+        }
+    }
+    if err := zw.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// 生成只有文件头的桩可执行文件，机器类型与平台一致，能通过 verifyBinaryArch
+func stubBinary(platform string) []byte {
+    osName, _, _ := strings.Cut(platform, "-")
+    arch := platformArch(platform)
+    var buf bytes.Buffer
+    le := binary.LittleEndian
+
+    switch osName {
+    case "linux":
+        class := elf.ELFCLASS64
+        if arch == "armv7l" || arch == "armv6l" || arch == "x86" {
+            class = elf.ELFCLASS32
+        }
+        buf.Write([]byte{0x7f, 'E', 'L', 'F', byte(class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)})
+        buf.Write(make([]byte, 9))
+        binary.Write(&buf, le, uint16(elf.ET_EXEC))
+        binary.Write(&buf, le, uint16(elfMachines[arch]))
+        binary.Write(&buf, le, uint32(elf.EV_CURRENT))
+        // 其余字段全部为 0: 无程序头和节头
+        if class == elf.ELFCLASS64 {
+            buf.Write(make([]byte, 64-buf.Len()))
+        } else {
+            buf.Write(make([]byte, 52-buf.Len()))
+        }
+    case "darwin":
+        binary.Write(&buf, le, uint32(macho.Magic64))
+        binary.Write(&buf, le, uint32(machoCPUs[arch]))
+        binary.Write(&buf, le, uint32(0))
+        binary.Write(&buf, le, uint32(macho.TypeExec))
+        buf.Write(make([]byte, 16))
+    case "win":
+        dos := make([]byte, 0x40)
+        dos[0], dos[1] = 'M', 'Z'
+        le.PutUint32(dos[0x3c:], 0x40)
+        buf.Write(dos)
+        buf.WriteString("PE\x00\x00")
+        binary.Write(&buf, le, peMachines[arch])
+        buf.Write(make([]byte, 18))
+    }
+    buf.WriteString(fmt.Sprintf("fixture node %s\n", platform))
+    return buf.Bytes()
+}
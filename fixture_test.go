@@ -0,0 +1,142 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "testing"
+)
+
+const fixtureVersion = "v20.0.0"
+
+// 启动夹具服务并把来源指向它，测试结束后恢复全局设置
+func serveFixture(t *testing.T, platforms ...string) *fixture {
+    t.Helper()
+    fx, err := newFixture(fixtureVersion, platforms)
+    if err != nil {
+        t.Fatal(err)
+    }
+    srv := fx.Serve()
+    saved, savedSource, savedClient := opts, releaseSource, httpClient
+    t.Cleanup(func() {
+        srv.Close()
+        opts, releaseSource, httpClient = saved, savedSource, savedClient
+    })
+    releaseSource = &distSource{Base: srv.URL}
+    httpClient = &http.Client{Transport: &http.Transport{}}
+    opts.Channel = "lts"
+    return fx
+}
+
+func fixtureArchive(t *testing.T, fx *fixture, platform string) []byte {
+    t.Helper()
+    data, ok := fx.Files[path.Join(fixtureVersion, archiveName(fixtureVersion, platform))]
+    if !ok {
+        t.Fatalf("夹具中没有 %s 的压缩包", platform)
+    }
+    return data
+}
+
+func TestFixtureResolveVersion(t *testing.T) {
+    serveFixture(t, "linux-x64")
+    got, err := resolveVersion(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != fixtureVersion {
+        t.Fatalf("resolveVersion = %s，期望 %s", got, fixtureVersion)
+    }
+}
+
+func TestFixtureDownloadMatchesShasums(t *testing.T) {
+    fx := serveFixture(t, "linux-x64", "win-x64")
+    saved := needSourceHash
+    needSourceHash = true
+    defer func() { needSourceHash = saved }()
+
+    sums, err := parseShasums(bytes.NewReader(fx.Files[path.Join(fixtureVersion, "SHASUMS256.txt")]))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, platform := range []string{"linux-x64", "win-x64"} {
+        filename := filepath.Join(t.TempDir(), "archive.tmp")
+        sum, err := downloadFile(context.Background(), filename, buildURL(fixtureVersion, platform), platform, &resumeState{})
+        if err != nil {
+            t.Fatalf("%s: %v", platform, err)
+        }
+        if want := sums[archiveName(fixtureVersion, platform)]; sum != want {
+            t.Fatalf("%s: SHA-256 %s，SHASUMS256.txt 为 %s", platform, sum, want)
+        }
+        got, err := os.ReadFile(filename)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !bytes.Equal(got, fixtureArchive(t, fx, platform)) {
+            t.Fatalf("%s: 下载内容与夹具不一致", platform)
+        }
+    }
+}
+
+func TestFixtureExtractNodeTarXZ(t *testing.T) {
+    fx := serveFixture(t, "linux-arm64")
+    var buf bytes.Buffer
+    if err := ExtractNode(bytes.NewReader(fixtureArchive(t, fx, "linux-arm64")), ArchiveTarXZ, &buf, ExtractOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(buf.Bytes(), stubBinary("linux-arm64")) {
+        t.Fatalf("解压出的 bin/node 与夹具不一致")
+    }
+}
+
+func TestFixtureExtractNodeZip(t *testing.T) {
+    fx := serveFixture(t, "win-x64")
+    data := fixtureArchive(t, fx, "win-x64")
+    var buf bytes.Buffer
+    if err := ExtractNodeZip(bytes.NewReader(data), int64(len(data)), &buf, ExtractOptions{}); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(buf.Bytes(), stubBinary("win-x64")) {
+        t.Fatalf("解压出的 node.exe 与夹具不一致")
+    }
+}
+
+// 各输出格式压缩后都能解压回原来的可执行文件
+func TestFixtureCompressRoundTrip(t *testing.T) {
+    saved := opts
+    defer func() { opts = saved }()
+    opts.ZstdLevel = "default"
+    opts.BrotliQuality = 5
+    input := filepath.Join(t.TempDir(), "node")
+    if err := os.WriteFile(input, stubBinary("linux-x64"), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    for _, format := range []string{"zstd", "gzip", "brotli"} {
+        opts.Format = format
+        c := compressors[format]
+        output := filepath.Join(t.TempDir(), "node_linux_amd64"+c.Ext)
+        if err := compressFile(input, output, "linux-x64"); err != nil {
+            t.Fatalf("%s: %v", format, err)
+        }
+        f, err := os.Open(output)
+        if err != nil {
+            t.Fatal(err)
+        }
+        dec, err := c.NewReader(f)
+        if err != nil {
+            f.Close()
+            t.Fatalf("%s: %v", format, err)
+        }
+        got, err := io.ReadAll(dec)
+        f.Close()
+        if err != nil {
+            t.Fatalf("%s: 解压失败: %v", format, err)
+        }
+        if !bytes.Equal(got, stubBinary("linux-x64")) {
+            t.Fatalf("%s: 解压结果与输入不一致", format)
+        }
+    }
+}
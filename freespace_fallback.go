@@ -0,0 +1,12 @@
+//go:build !windows && !linux && !darwin && !freebsd && !dragonfly && !openbsd && !netbsd
+
+package main
+
+// 其他平台不检查空间，返回 -1 表示未知，-min-free-space 和 inode 检查都视为满足
+func freeSpace(dir string) (int64, error) {
+    return -1, nil
+}
+
+func freeInodes(dir string) (int64, error) {
+    return -1, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+    "golang.org/x/sys/unix"
+)
+
+// NetBSD 没有 statfs，用 statvfs；可用块数按片段大小 (Frsize) 计
+func freeSpace(dir string) (int64, error) {
+    var st unix.Statvfs_t
+    if err := unix.Statvfs(dir, &st); err != nil {
+        return 0, err
+    }
+    return int64(st.Bavail * st.Frsize), nil
+}
+
+// 剩余 inode 数；总数为 0 时返回 -1 表示不限制
+func freeInodes(dir string) (int64, error) {
+    var st unix.Statvfs_t
+    if err := unix.Statvfs(dir, &st); err != nil {
+        return 0, err
+    }
+    if st.Files == 0 {
+        return -1, nil
+    }
+    return int64(st.Ffree), nil
+}
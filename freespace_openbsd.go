@@ -0,0 +1,26 @@
+package main
+
+import (
+    "golang.org/x/sys/unix"
+)
+
+// 非特权用户可用的剩余空间
+func freeSpace(dir string) (int64, error) {
+    var st unix.Statfs_t
+    if err := unix.Statfs(dir, &st); err != nil {
+        return 0, err
+    }
+    return st.F_bavail * int64(st.F_bsize), nil
+}
+
+// 剩余 inode 数；总数为 0 时返回 -1 表示不限制
+func freeInodes(dir string) (int64, error) {
+    var st unix.Statfs_t
+    if err := unix.Statfs(dir, &st); err != nil {
+        return 0, err
+    }
+    if st.F_files == 0 {
+        return -1, nil
+    }
+    return int64(st.F_ffree), nil
+}
@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package main
+
+import (
+    "syscall"
+)
+
+// 非特权用户可用的剩余空间
+func freeSpace(dir string) (int64, error) {
+    var st syscall.Statfs_t
+    if err := syscall.Statfs(dir, &st); err != nil {
+        return 0, err
+    }
+    return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
+
+// 剩余 inode 数；btrfs 等动态分配 inode 的文件系统报告总数为 0，此时返回 -1 表示不限制
+func freeInodes(dir string) (int64, error) {
+    var st syscall.Statfs_t
+    if err := syscall.Statfs(dir, &st); err != nil {
+        return 0, err
+    }
+    if st.Files == 0 {
+        return -1, nil
+    }
+    return int64(st.Ffree), nil
+}
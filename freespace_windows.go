@@ -0,0 +1,25 @@
+//go:build windows
+
+package main
+
+import (
+    "golang.org/x/sys/windows"
+)
+
+// 当前用户可用的剩余空间 (考虑磁盘配额)
+func freeSpace(dir string) (int64, error) {
+    p, err := windows.UTF16PtrFromString(longPath(dir))
+    if err != nil {
+        return 0, err
+    }
+    var avail, total, totalFree uint64
+    if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &totalFree); err != nil {
+        return 0, err
+    }
+    return int64(avail), nil
+}
+
+// NTFS 没有固定的 inode 上限，返回 -1 表示不限制
+func freeInodes(dir string) (int64, error) {
+    return -1, nil
+}
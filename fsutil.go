@@ -0,0 +1,54 @@
+package main
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+)
+
+// 临时文件路径: 默认与输出同目录，指定 -tmp-dir 时放到该目录
+// 各版本的同名输出共用 -tmp-dir，文件名前加上版本号，避免 -version-concurrency 同时构建的版本互相覆盖
+func tempPath(outFile, version, suffix string) string {
+    if opts.TmpDir == "" {
+        return outFile + suffix
+    }
+    name := filepath.Base(outFile)
+    if version != "" {
+        name = version + "-" + name
+    }
+    return longPath(filepath.Join(opts.TmpDir, name+suffix))
+}
+
+// 移动文件；跨文件系统改名失败时先复制到目标目录的 .part 再改名，保证最终落盘仍是原子的
+func moveFile(src, dst string) error {
+    if err := os.Rename(src, dst); err == nil {
+        return nil
+    }
+
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+
+    info, err := in.Stat()
+    if err != nil {
+        return err
+    }
+    err = writeAtomic(dst, func(part string) error {
+        out, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+        if err != nil {
+            return err
+        }
+        if _, err := io.Copy(out, in); err != nil {
+            out.Close()
+            return err
+        }
+        return out.Close()
+    })
+    if err != nil {
+        return err
+    }
+    in.Close()
+    return os.Remove(src)
+}
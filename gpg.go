@@ -0,0 +1,116 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "time"
+
+    "github.com/ProtonMail/go-crypto/openpgp"
+    "github.com/ProtonMail/go-crypto/openpgp/clearsign"
+    "github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// -gpg-keyring 读入的发布者公钥
+var gpgKeyring openpgp.EntityList
+
+// 读取公钥文件，支持 ASCII armor 和二进制两种格式
+func loadGPGKeyring(path string) (openpgp.EntityList, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+    if err != nil {
+        keys, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+    }
+    if err != nil {
+        return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+    }
+    if len(keys) == 0 {
+        return nil, fmt.Errorf("%s 中没有公钥", path)
+    }
+    return keys, nil
+}
+
+// 签名缺失之外的签名问题 (格式不对、签名无效)，属于校验失败而不是下载失败
+type signatureError struct{ error }
+
+func (e *signatureError) Unwrap() error { return e.error }
+
+// 校验签名版 SHASUMS256.txt.asc，返回签名覆盖的正文
+// 之后只解析这份正文，不再使用未签名的 SHASUMS256.txt，保证用到的校验和都经过签名
+func verifyClearsigned(data []byte, source string) ([]byte, error) {
+    block, _ := clearsign.Decode(data)
+    if block == nil {
+        return nil, &signatureError{fmt.Errorf("%s 不是 PGP 签名文件", source)}
+    }
+    signer, err := openpgp.CheckDetachedSignature(gpgKeyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, nil)
+    if err != nil {
+        return nil, &signatureError{fmt.Errorf("%s 签名校验失败: %w", source, err)}
+    }
+    logf(levelPhase, "🔏 %s 签名有效 (%s)\n", source, signerName(signer))
+    return block.Plaintext, nil
+}
+
+func signerName(e *openpgp.Entity) string {
+    for name := range e.Identities {
+        return name
+    }
+    return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+}
+
+// -sign-key 读入并已解密的签名私钥，未设置时为 nil
+var signingKey *packet.PrivateKey
+
+// 读取 -sign-key 私钥文件 (armor 或二进制)，取第一个带私钥的密钥中当前可用于签名的 (子) 密钥
+// 私钥加密时用 passphrase 解密
+func loadSigningKey(path, passphrase string) (*packet.PrivateKey, error) {
+    keys, err := loadGPGKeyring(path)
+    if err != nil {
+        return nil, err
+    }
+    for _, e := range keys {
+        if e.PrivateKey == nil {
+            continue
+        }
+        key, ok := e.SigningKey(time.Now())
+        if !ok || key.PrivateKey == nil {
+            return nil, fmt.Errorf("%s (%s) 没有可用于签名的私钥", path, signerName(e))
+        }
+        if key.PrivateKey.Encrypted {
+            if passphrase == "" {
+                return nil, fmt.Errorf("%s 的私钥已加密，需要 -sign-passphrase 或 %s", path, envName("sign-passphrase"))
+            }
+            if err := e.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+                return nil, fmt.Errorf("解密 %s 失败: %w", path, err)
+            }
+        }
+        logf(levelPhase, "🔏 校验和文件将由 %s 签名\n", signerName(e))
+        return key.PrivateKey, nil
+    }
+    return nil, fmt.Errorf("%s 中没有私钥", path)
+}
+
+// 写出 path 的签名版 <path>.asc (clearsign，与 Node 官方的 SHASUMS256.txt.asc 相同)，
+// 下游可以像校验上游一样用 gpg --verify 或本工具的 -verify-gpg 校验
+func signShasums(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var buf bytes.Buffer
+    w, err := clearsign.Encode(&buf, signingKey, nil)
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(data); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return writeAtomic(path+".asc", func(part string) error {
+        return os.WriteFile(part, buf.Bytes(), 0o644)
+    })
+}
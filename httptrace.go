@@ -0,0 +1,129 @@
+package main
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// -http-trace 中的一行: 一次 HTTP 请求 (重定向的每一跳各算一次)
+type traceRecord struct {
+    Time       time.Time         `json:"time"`
+    Method     string            `json:"method"`
+    URL        string            `json:"url"`
+    Mirror     string            `json:"mirror"` // scheme://host
+    Headers    map[string]string `json:"headers,omitempty"`
+    Status     int               `json:"status,omitempty"`
+    Bytes      int64             `json:"bytes"`
+    DurationMS int64             `json:"durationMs"` // 从发出请求到响应体关闭
+    Error      string            `json:"error,omitempty"`
+}
+
+// 这些请求头可能带凭据，写入 trace 时替换掉
+var redactedHeaders = map[string]bool{
+    "Authorization":        true,
+    "Proxy-Authorization":  true,
+    "Cookie":               true,
+    "X-Amz-Security-Token": true,
+}
+
+// 包装共享 Transport，把每个请求记录为一行 JSON
+type traceTransport struct {
+    base http.RoundTripper
+    mu   sync.Mutex
+    out  io.Writer
+}
+
+func newTraceTransport(base http.RoundTripper, path string) (*traceTransport, error) {
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+    if err != nil {
+        return nil, err
+    }
+    return &traceTransport{base: base, out: f}, nil
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    rec := &traceRecord{
+        Time:    time.Now(),
+        Method:  req.Method,
+        URL:     req.URL.Redacted(),
+        Mirror:  req.URL.Scheme + "://" + req.URL.Host,
+        Headers: traceHeaders(req.Header),
+    }
+    resp, err := t.base.RoundTrip(req)
+    if err != nil {
+        rec.Error = err.Error()
+        t.write(rec)
+        return nil, err
+    }
+    rec.Status = resp.StatusCode
+    resp.Body = &traceBody{ReadCloser: resp.Body, t: t, rec: rec}
+    return resp, nil
+}
+
+// 记录实际发出的请求头 (如 Range、If-Range)，凭据类的值替换为 REDACTED
+func traceHeaders(h http.Header) map[string]string {
+    if len(h) == 0 {
+        return nil
+    }
+    out := make(map[string]string, len(h))
+    for k, v := range h {
+        if redactedHeaders[http.CanonicalHeaderKey(k)] {
+            out[k] = "REDACTED"
+            continue
+        }
+        out[k] = strings.Join(v, ", ")
+    }
+    return out
+}
+
+func (t *traceTransport) write(rec *traceRecord) {
+    rec.DurationMS = time.Since(rec.Time).Milliseconds()
+    line, err := json.Marshal(rec)
+    if err != nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    // 写 trace 失败不影响下载本身
+    t.out.Write(append(line, '\n'))
+}
+
+// 统计读取的响应体字节数，关闭时写出记录
+type traceBody struct {
+    io.ReadCloser
+    t    *traceTransport
+    rec  *traceRecord
+    once sync.Once
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+    n, err := b.ReadCloser.Read(p)
+    b.rec.Bytes += int64(n)
+    if err != nil && err != io.EOF {
+        b.rec.Error = err.Error()
+    }
+    return n, err
+}
+
+func (b *traceBody) Close() error {
+    err := b.ReadCloser.Close()
+    b.once.Do(func() {
+        b.t.write(b.rec)
+    })
+    return err
+}
+
+// 共享客户端底层的 *http.Transport，开启 -http-trace 时穿过包装取出
+func sharedTransport() (*http.Transport, bool) {
+    rt := httpClient.Transport
+    if t, ok := rt.(*traceTransport); ok {
+        rt = t.base
+    }
+    tr, ok := rt.(*http.Transport)
+    return tr, ok
+}
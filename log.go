@@ -0,0 +1,45 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strings"
+
+    "golang.org/x/term"
+)
+
+// 日志级别，数值越大输出越多
+type logLevel int
+
+const (
+    levelError   logLevel = iota // 错误，总是输出
+    levelSummary                 // 每个目标的完成行和最终汇总
+    levelPhase                   // 下载/解压/压缩等阶段日志和进度
+)
+
+var verbosity = levelPhase
+
+// 是否输出 \r 刷新的进度等终端控制字符，解析参数后由 shouldUseANSI 决定
+var useANSI = true
+
+// -force-color 优先；其次 NO_COLOR 环境变量或 -no-color 关闭；否则仅在标准输出是终端时开启
+func shouldUseANSI() bool {
+    if opts.ForceColor {
+        return true
+    }
+    if opts.NoColor || os.Getenv("NO_COLOR") != "" {
+        return false
+    }
+    return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func logf(level logLevel, format string, args ...any) {
+    if level > verbosity {
+        return
+    }
+    if !useANSI {
+        // 不回到行首覆盖，阶段结束行照常单独输出
+        format = strings.TrimPrefix(format, "\r")
+    }
+    fmt.Printf(format, args...)
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// 非 Windows 平台没有路径长度限制
+func longPath(p string) string {
+    return p
+}
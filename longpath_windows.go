@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+    "path/filepath"
+    "strings"
+)
+
+// Windows 传统 API 的 MAX_PATH 限制，留出文件名余量
+const maxShortPath = 248
+
+// 输出目录很深时改用 \\?\ 扩展路径，避免 CI 工作区路径过长导致写入失败
+func longPath(p string) string {
+    if strings.HasPrefix(p, `\\?\`) {
+        return p
+    }
+    abs, err := filepath.Abs(p)
+    if err != nil || len(abs) < maxShortPath {
+        return p
+    }
+    if strings.HasPrefix(abs, `\\`) {
+        // UNC 路径: \\server\share -> \\?\UNC\server\share
+        return `\\?\UNC\` + abs[2:]
+    }
+    return `\\?\` + abs
+}
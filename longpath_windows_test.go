@@ -0,0 +1,54 @@
+//go:build windows
+
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestLongPathShortUnchanged(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "out")
+    if got := longPath(p); got != p {
+        t.Fatalf("短路径不应改写: %q -> %q", p, got)
+    }
+}
+
+func TestLongPathUNC(t *testing.T) {
+    p := `\\server\share\` + strings.Repeat(`d\`, maxShortPath/2) + "node.zst"
+    got := longPath(p)
+    if !strings.HasPrefix(got, `\\?\UNC\server\share\`) {
+        t.Fatalf("UNC 路径应改写为 \\\\?\\UNC\\ 形式: %q", got)
+    }
+}
+
+// 模拟 CI 上很深的工作区: 输出目录超过 MAX_PATH，经 longPath 后仍能创建、写入和读回
+func TestLongPathDeepOutputDir(t *testing.T) {
+    dir := t.TempDir()
+    for len(dir) < 300 {
+        dir = filepath.Join(dir, strings.Repeat("x", 40))
+    }
+    out := filepath.Join(dir, "node_windows_amd64.zst")
+    if !strings.HasPrefix(longPath(out), `\\?\`) {
+        t.Fatalf("超长路径应加 \\\\?\\ 前缀: %q", longPath(out))
+    }
+    if got := longPath(longPath(out)); got != longPath(out) {
+        t.Fatalf("已有前缀的路径不应再次改写: %q", got)
+    }
+    if err := os.MkdirAll(longPath(dir), 0o755); err != nil {
+        t.Fatalf("创建深层目录失败: %v", err)
+    }
+    want := []byte("compressed output")
+    if err := os.WriteFile(longPath(out), want, 0o644); err != nil {
+        t.Fatalf("写入深层输出失败: %v", err)
+    }
+    got, err := os.ReadFile(longPath(out))
+    if err != nil {
+        t.Fatalf("读回深层输出失败: %v", err)
+    }
+    if string(got) != string(want) {
+        t.Fatalf("读回内容不一致: %q", got)
+    }
+}
@@ -1,25 +1,20 @@
 package main
 
 import (
-    "archive/tar"
-    "archive/zip"
+    "context"
     "encoding/json"
+    "errors"
+    "flag"
     "fmt"
     "io"
     "net/http"
     "os"
+    "path/filepath"
+    "regexp"
     "strings"
-    "sync"
     "time"
-
-    "github.com/klauspost/compress/zstd"
-    "github.com/ulikunitz/xz"
 )
 
-type NodeVersion struct {
-    Version string      `json:"version"`
-    LTS     interface{} `json:"lts"`
-}
 
 var targets = map[string]string{
     "node_darwin_amd64.zst":  "darwin-x64",
@@ -32,218 +27,693 @@ var targets = map[string]string{
     "node_windows_i386.zst":  "win-x86",
 }
 
-// 进度条 Writer
-type ProgressWriter struct {
-    Total      int64
-    Written    int64
-    LastUpdate time.Time
-    Prefix     string
+// 按 -platforms / -only 过滤目标，未指定时返回全部
+func selectTargets() (map[string]string, error) {
+    want := opts.Platforms
+    if opts.Only != "" {
+        want = []string{opts.Only}
+    }
+    if len(want) == 0 {
+        return targets, nil
+    }
+
+    byPlatform := make(map[string]string, len(targets))
+    for outFile, platform := range targets {
+        byPlatform[platform] = outFile
+    }
+    selected := make(map[string]string, len(want))
+    for _, platform := range want {
+        outFile, ok := byPlatform[platform]
+        if !ok {
+            return nil, fmt.Errorf("不支持的平台: %s", platform)
+        }
+        selected[outFile] = platform
+    }
+    return selected, nil
+}
+
+// 输出路径: -out 目录下加上 -prefix 的文件名，临时文件和中间文件都由它派生
+// 扩展名随 -format 变化
+func outputName(out, outFile string) string {
+    outFile = strings.TrimSuffix(outFile, ".zst") + compressors[opts.Format].Ext
+    return longPath(filepath.Join(out, opts.Prefix+outFile))
+}
+
+// -raw-binary 的输出名: 去掉压缩扩展名，Windows 目标补上 .exe
+func rawBinaryName(outFile, platform string) string {
+    name := strings.TrimSuffix(outFile, filepath.Ext(outFile))
+    if strings.HasPrefix(platform, "win") {
+        name += ".exe"
+    }
+    return name
 }
 
-func (pw *ProgressWriter) Write(p []byte) (int, error) {
-    n := len(p)
-    pw.Written += int64(n)
-    now := time.Now()
-    if now.Sub(pw.LastUpdate) > 300*time.Millisecond {
-        pw.LastUpdate = now
-        percent := float64(pw.Written) / float64(pw.Total) * 100
-        fmt.Printf("\r%s %.1f%%", pw.Prefix, percent)
+// 跳过压缩，把解压出的可执行文件直接放到输出路径并设置可执行权限
+func placeRawBinary(exeFile string, res *targetResult) error {
+    name := rawBinaryName(res.OutFile, res.Platform)
+    if err := moveFile(exeFile, name); err != nil {
+        return err
+    }
+    if err := os.Chmod(name, 0o755); err != nil {
+        return err
     }
-    return n, nil
+    res.OutFile = name
+    return nil
+}
+
+// 退出码
+const (
+    exitOK      = 0 // 全部目标成功
+    exitFailure = 1 // 没有目标成功，或启动阶段 (参数、版本解析等) 出错
+    exitPartial = 2 // 部分目标成功
+)
+
+// 启动阶段的致命错误
+func fatal(err error) {
+    logf(levelError, "❌ %v\n", err)
+    os.Exit(exitFailure)
 }
 
 func main() {
-    version, err := fetchLatestLTS()
+    if err := parseFlags(); err != nil {
+        if errors.Is(err, flag.ErrHelp) {
+            os.Exit(exitOK)
+        }
+        os.Exit(exitFailure)
+    }
+    if err := validateOptions(); err != nil {
+        fatal(fmt.Errorf("参数错误: %w", err))
+    }
+    if opts.PrintConfig {
+        if err := printConfig(); err != nil {
+            fatal(err)
+        }
+        return
+    }
+    if opts.SelfTest {
+        os.Exit(runSelfTest())
+    }
+    if opts.VerifyOnly {
+        os.Exit(runVerifyOnly())
+    }
+    if opts.RefreshMeta {
+        os.Exit(runRefreshMetadata())
+    }
+    if opts.Prune {
+        os.Exit(runPrune())
+    }
+    if opts.CompressOnly != "" {
+        os.Exit(runCompressOnly())
+    }
+
+    client, err := newHTTPClient()
     if err != nil {
-        panic(err)
+        fatal(err)
     }
-    fmt.Println("最新 LTS 版本:", version)
+    httpClient = client
+    initRetryLimiter()
 
-    var wg sync.WaitGroup
-    wg.Add(len(targets))
+    for _, dir := range []string{opts.Out, opts.TmpDir} {
+        if dir == "" {
+            continue
+        }
+        if err := os.MkdirAll(longPath(dir), 0o755); err != nil {
+            fatal(err)
+        }
+    }
 
-    sem := make(chan struct{}, 3) // 限制最大并发数为3
+    ctx := context.Background()
+    if opts.S3Bucket != "" {
+        if s3Store, err = newS3Uploader(ctx); err != nil {
+            fatal(err)
+        }
+    }
+    var updateCh <-chan string
+    if opts.CheckUpdate {
+        updateCh = startUpdateCheck(ctx)
+    }
 
-    for outFile, platform := range targets {
-        go func(outFile, platform string) {
-            defer wg.Done()
-            sem <- struct{}{}
-            defer func() { <-sem }()
-
-            if err := processTarget(version, outFile, platform); err != nil {
-                fmt.Printf("\n❌ %s 失败: %v\n", outFile, err)
-            } else {
-                fmt.Printf("\n✅ 完成: %s\n", outFile)
-            }
-        }(outFile, platform)
+    if opts.DumpURLs {
+        if err := dumpURLs(ctx); err != nil {
+            fatal(err)
+        }
+        return
+    }
+    if opts.Benchmark {
+        if err := runBenchmark(ctx); err != nil {
+            fatal(err)
+        }
+        return
+    }
+    if opts.DryRun {
+        if err := runDryRun(ctx); err != nil {
+            fatal(err)
+        }
+        return
     }
 
-    wg.Wait()
-    fmt.Println("\n🎉 全部完成")
+    if opts.Interval > 0 {
+        runDaemon(ctx, updateCh)
+        return
+    }
+    if len(opts.Versions) > 0 {
+        code, err := runVersions(ctx)
+        if err != nil {
+            fatal(err)
+        }
+        reportUpdate(updateCh)
+        os.Exit(code)
+    }
+
+    _, code, err := runPipeline(ctx)
+    if err != nil {
+        fatal(err)
+    }
+    reportUpdate(updateCh)
+    os.Exit(code)
 }
 
-func fetchLatestLTS() (string, error) {
-    resp, err := http.Get("https://nodejs.org/dist/index.json")
+// 完整执行一轮: 确定版本、构建所选目标并输出汇总
+// 返回本轮版本和退出码，启动阶段的错误 (版本解析、参数等) 通过 err 返回
+func runPipeline(ctx context.Context) (string, int, error) {
+    version, err := resolveVersion(ctx)
     if err != nil {
-        return "", err
+        return "", exitFailure, err
+    }
+    logf(levelSummary, "Node 版本: %s\n", version)
+    if version == lastBuiltVersion {
+        logf(levelSummary, "⏭️  版本未变化，跳过本轮\n")
+        return version, exitOK, nil
     }
-    defer resp.Body.Close()
 
-    var versions []NodeVersion
-    if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
-        return "", err
+    selected, err := prepareTargets(1)
+    if err != nil {
+        return version, exitFailure, err
+    }
+    if err := loadRunStateFile(); err != nil {
+        return version, exitFailure, err
     }
-    for _, v := range versions {
-        if v.LTS != false && v.LTS != nil {
-            return v.Version, nil
+    initStageLimits()
+    if opts.Deadline > 0 {
+        deadlineAt = time.Now().Add(opts.Deadline)
+    }
+    _, code, err := buildVersion(ctx, version, opts.Out, selected)
+    if err != nil {
+        return version, exitFailure, err
+    }
+    if opts.EmitSRI != "" {
+        if err := emitSRI(); err != nil {
+            logf(levelError, "⚠️  写出 -emit-sri 失败: %v\n", err)
         }
     }
-    return "", fmt.Errorf("未找到 LTS 版本")
+    if code == exitOK {
+        if err := runStateFile.finish(); err != nil {
+            logf(levelError, "⚠️  删除 -run-state 文件失败: %v\n", err)
+        }
+    }
+    return version, code, nil
 }
 
-func processTarget(version, outFile, platform string) error {
-    url := buildURL(version, platform)
-    fmt.Printf("\n⬇️  下载 %s -> %s\n", url, outFile)
+// 选出本轮目标并据此确定各阶段并发和内存预算
+// versions 为同时构建的版本数，共享的并发名额和预计文件数按全部同时进行的目标计算
+func prepareTargets(versions int) (map[string]string, error) {
+    selected, err := selectTargets()
+    if err != nil {
+        return nil, err
+    }
+    inFlight := len(selected) * versions
+    applyConcurrency(inFlight)
+    expectedFiles = int64(inFlight) * filesPerTarget()
+    applyMemoryBudget(inFlight)
 
-    tmpFile := outFile + ".tmp"
-    if err := downloadFile(tmpFile, url, platform); err != nil {
-        return err
+    if opts.Provenance || opts.SidecarMeta || opts.EmitSRI != "" || opts.S3Bucket != "" {
+        needSourceHash = true
     }
-    defer os.Remove(tmpFile)
+    if opts.Checksum && opts.SourceDir == "" {
+        needSourceHash = true
+    }
+    return selected, nil
+}
 
-    exeFile := outFile + ".nodebin"
-    if strings.HasPrefix(platform, "win") {
-        if err := extractNodeFromZip(tmpFile, exeFile, platform); err != nil {
-            return err
+func loadRunStateFile() error {
+    if opts.RunState == "" {
+        return nil
+    }
+    s, err := loadRunState(opts.RunState)
+    if err != nil {
+        return fmt.Errorf("读取 -run-state 失败: %w", err)
+    }
+    runStateFile = s
+    return nil
+}
+
+// 把一个版本的所选目标构建到 out 目录并输出汇总，返回全部结果和退出码
+// -version-concurrency 时多个版本同时调用，这里只读 opts，不修改任何全局设置
+func buildVersion(parent context.Context, version, out string, selected map[string]string) ([]*targetResult, int, error) {
+    started := time.Now()
+    ctx, cancel := context.WithCancelCause(parent)
+    defer cancel(nil)
+
+    // 校验和与下载并行获取，不阻塞首批下载；同一地址在缓存清空前只获取一次
+    if opts.Checksum && opts.SourceDir == "" {
+        remoteShasums(ctx, releaseSource.ChecksumURL(version))
+    }
+
+    var live string
+    if opts.ReplaceAtomic {
+        live = out
+        staged, err := stageOutput(live)
+        if err != nil {
+            return nil, exitFailure, err
         }
-    } else {
-        if err := extractNodeFromTarXZ(tmpFile, exeFile, platform); err != nil {
-            return err
+        out = staged
+    }
+
+    results := runTargets(ctx, cancel, version, out, selected)
+    if opts.Dedupe {
+        warnDuplicates(results)
+    }
+
+    var failed, timedOut int
+    for _, res := range results {
+        if res.Err != nil {
+            failed++
         }
+        if errors.Is(res.Err, errDeadline) {
+            timedOut++
+        }
+    }
+    if timedOut > 0 {
+        logf(levelSummary, "\n⏱️  -deadline %s 已到，%d 个目标未开始\n", opts.Deadline, timedOut)
     }
+    total := len(results)
+    if missing := missingTargets(selected, results); len(missing) > 0 {
+        logf(levelError, "\n❌ %d 个请求的目标没有产出输出:\n", len(missing))
+        for _, m := range missing {
+            logf(levelError, "   - %s\n", m)
+        }
+        failed += len(missing)
+        total = max(total, len(selected))
+    }
+    // 原子输出时失败的构建整个丢弃，不写校验和也不上传；校验和文件在切换前写入暂存目录，与输出一同发布
+    keep := live == "" || failed == 0
+    if keep {
+        if err := writeOutputShasums(out, results); err != nil {
+            logf(levelError, "❌ 写出校验和文件失败: %v\n", err)
+            failed, keep = total, false
+        }
+    }
+    upload := s3Store != nil && keep
+    if live != "" {
+        if failed > 0 {
+            discardStaged(live, out)
+        } else if err := publishStaged(live, out, results); err != nil {
+            logf(levelError, "❌ %v\n", err)
+            failed, upload = total, false
+        } else {
+            out = live
+        }
+    }
+    if upload {
+        failed += uploadVersion(parent, out, results)
+        failed = min(failed, total)
+    }
+    if opts.Only == "" {
+        logf(levelSummary, "\n🎉 %s 全部完成: 成功 %d，失败 %d\n", version, total-failed, failed)
+    }
+    printTotals(results, time.Since(started))
+    if opts.SpeedSamples {
+        if err := reportSpeedSamples(version, results); err != nil {
+            logf(levelError, "⚠️  写出 -speed-samples-json 失败: %v\n", err)
+        }
+    }
+    if opts.EmitSRI != "" {
+        recordSRI(results)
+    }
+    if opts.ReportHTML != "" {
+        if err := recordReport(version, started, results); err != nil {
+            logf(levelError, "⚠️  写出 -report-html 失败: %v\n", err)
+        }
+    }
+    return results, exitCode(total, failed), nil
+}
 
-    if err := compressZstd(exeFile, outFile, platform); err != nil {
-        return err
+func exitCode(total, failed int) int {
+    switch {
+    case failed == 0:
+        return exitOK
+    case failed < total:
+        return exitPartial
+    default:
+        return exitFailure
     }
-    os.Remove(exeFile)
-    return nil
 }
 
-func buildURL(version, platform string) string {
-    ext := ".tar.xz"
-    if strings.HasPrefix(platform, "win") {
-        ext = ".zip"
+// 确定要构建的版本: -version > -source-dir 推断 > 按 -channel 等条件从 index.json 选择
+func resolveVersion(ctx context.Context) (string, error) {
+    if opts.Version != "" {
+        return opts.Version, nil
+    }
+    if opts.SourceDir != "" {
+        return detectLocalVersion()
+    }
+    versions, err := loadIndex(ctx)
+    if err != nil {
+        return "", err
+    }
+    version, err := selectVersion(versions, criteriaFromOptions())
+    if err != nil {
+        return "", err
     }
-    return fmt.Sprintf("https://nodejs.org/dist/%s/node-%s-%s%s",
-        version, version, platform, ext)
+    return version, checkFreshness(versions, version)
 }
 
-func downloadFile(filename, url, platform string) error {
-    resp, err := http.Get(url)
+// 获取版本索引 index.json，按发布时间从新到旧排列
+func fetchIndex(ctx context.Context) ([]NodeVersion, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseSource.IndexURL(), nil)
     if err != nil {
-        return err
+        return nil, err
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return nil, err
     }
     defer resp.Body.Close()
 
-    out, err := os.Create(filename)
-    if err != nil {
-        return err
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("获取 index.json 失败: %w", newHTTPStatusError(resp))
     }
-    defer out.Close()
 
-    pw := &ProgressWriter{Total: resp.ContentLength, Prefix: "下载[" + platform + "]"}
-    _, err = io.Copy(out, io.TeeReader(resp.Body, pw))
-    fmt.Printf("\r下载[%s] 100%%\n", platform)
-    return err
+    var versions []NodeVersion
+    if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+        return nil, err
+    }
+    return versions, nil
 }
 
-func extractNodeFromZip(zipPath, outFile, platform string) error {
-    r, err := zip.OpenReader(zipPath)
-    if err != nil {
-        return err
+func processTarget(ctx context.Context, version, outFile, platform string) (*targetResult, error) {
+    url := buildURL(version, platform)
+    res := &targetResult{OutFile: outFile, Platform: platform, Version: version, URL: url}
+
+    if streamable(platform) {
+        err := processTargetStreaming(ctx, res, outFile, platform)
+        if err == nil {
+            return res, finishTarget(res)
+        }
+        if !streamFallback(ctx, err) {
+            return res, err
+        }
+        var mm *checksumMismatch
+        if errors.As(err, &mm) {
+            logf(levelPhase, "\n↪️  流式处理[%s] 校验和不匹配，改用分步下载以便与备用镜像对比\n", platform)
+        } else {
+            logf(levelPhase, "\n↪️  流式处理[%s] 中断 (%v)，改用分步下载以便续传\n", platform, err)
+        }
+        *res = targetResult{OutFile: outFile, Platform: platform, Version: version, URL: url}
     }
-    defer r.Close()
 
-    for _, f := range r.File {
-        if strings.HasSuffix(f.Name, "node.exe") {
-            rc, err := f.Open()
-            if err != nil {
-                return err
+    var tmpFile string
+    var err error
+    if opts.SourceDir != "" {
+        tmpFile, res.SourceSHA256, err = localArchive(version, platform)
+        if err != nil {
+            return res, err
+        }
+        res.URL = tmpFile
+    } else {
+        logf(levelPhase, "\n⬇️  下载 %s -> %s\n", url, outFile)
+        tmpFile = tempPath(outFile, version, ".tmp")
+        slot, err := acquireDownload(ctx)
+        if err != nil {
+            return res, err
+        }
+        // 等待下载名额期间可能已经超过 -deadline
+        if err := checkDeadline(); err != nil {
+            slot.release()
+            return res, err
+        }
+        if err := waitForSpace(ctx, platform); err != nil {
+            slot.release()
+            return res, err
+        }
+        rs := &resumeState{}
+        alts := stallMirrors(version, platform, url)
+        err = withRetry(ctx, platform, func() error {
+            attemptCtx := ctx
+            if t := targetTimeout(platform); t > 0 {
+                var cancel context.CancelFunc
+                attemptCtx, cancel = context.WithTimeout(ctx, t)
+                defer cancel()
             }
-            defer rc.Close()
+            attemptCtx, stopStall := watchStall(attemptCtx)
+            sum, err := downloadFile(attemptCtx, tmpFile, url, platform, rs)
+            err = stopStall(err)
+            res.SourceSHA256 = sum
+            slot.report(err)
+            // 停滞的镜像重试多半还是停滞，有备用镜像时换一个；文件由主来源的 SHASUMS256.txt 校验
+            var se *stallError
+            if errors.As(err, &se) && len(alts) > 0 {
+                url, alts = alts[0], alts[1:]
+                res.URL = url
+                logf(levelSummary, "\n🐢 下载[%s] %v，改从 %s 下载\n", platform, err, url)
+            }
+            return err
+        })
+        slot.release()
+        if err != nil {
+            return res, &DownloadError{Platform: platform, URL: url, Err: err}
+        }
+        defer os.Remove(tmpFile)
 
-            out, err := os.Create(outFile)
+        if opts.Checksum {
+            sumsURL := targetSource(platform).ChecksumURL(version)
+            sums, err := remoteShasums(ctx, sumsURL).wait(ctx)
             if err != nil {
-                return err
+                return res, shasumsError(platform, sumsURL, err)
+            }
+            if err := verifyChecksum(sums, archiveName(version, platform), res.SourceSHA256, platform); err != nil {
+                var mm *checksumMismatch
+                if !errors.As(err, &mm) || len(opts.CrossCheckMirrors) == 0 {
+                    return res, &ChecksumError{Platform: platform, URL: url, Err: err}
+                }
+                alt, err := crossCheckMirrors(ctx, res, mm)
+                if err != nil {
+                    return res, &ChecksumError{Platform: platform, URL: url, Err: err}
+                }
+                defer os.Remove(alt)
+                tmpFile = alt
             }
-            defer out.Close()
+        }
+    }
 
-            _, err = io.Copy(out, rc)
-            fmt.Printf("解压[%s] node.exe 完成\n", platform)
-            return err
+    if info, err := os.Stat(tmpFile); err == nil {
+        res.SourceBytes = info.Size()
+    }
+
+    if len(opts.Extra) > 0 {
+        // 附加文件模式: 选中的成员以 tar 流直接送入压缩器，不落中间文件
+        // 解压与压缩同时进行，按 解压 -> 压缩 的固定顺序占用两个名额
+        releaseExtract, err := acquire(ctx, extractSem)
+        if err != nil {
+            return res, err
+        }
+        release, err := acquire(ctx, compressSem)
+        if err != nil {
+            releaseExtract()
+            return res, err
         }
+        start := time.Now()
+        err = writeCompressed(outFile, func(part string) error {
+            return compressBundle(tmpFile, part, platform)
+        })
+        res.CompressTime = time.Since(start)
+        release()
+        releaseExtract()
+        if err != nil {
+            return res, &CompressError{Platform: platform, URL: res.URL, Err: err}
+        }
+        return res, finishTarget(res)
     }
-    return fmt.Errorf("未找到 node.exe")
-}
 
-func extractNodeFromTarXZ(tarxzPath, outFile, platform string) error {
-    f, err := os.Open(tarxzPath)
+    exeFile := tempPath(outFile, version, ".nodebin")
+    releaseExtract, err := acquire(ctx, extractSem)
     if err != nil {
-        return err
+        return res, err
     }
-    defer f.Close()
-
-    xzr, err := xz.NewReader(f)
+    if strings.HasPrefix(platform, "win") {
+        err = extractNodeFromZip(tmpFile, exeFile, platform)
+    } else {
+        err = extractNodeFromTar(tmpFile, exeFile, platform)
+    }
+    releaseExtract()
     if err != nil {
+        return res, &ExtractError{Platform: platform, URL: res.URL, Err: err}
+    }
+    if err := verifyBinaryArch(exeFile, platform); err != nil {
+        os.Remove(exeFile)
+        return res, &ExtractError{Platform: platform, URL: res.URL, Err: err}
+    }
+    if opts.VerifyVersion {
+        checkBinaryVersion(exeFile, version, platform)
+    }
+    if opts.Dedupe {
+        if res.BinarySHA256, err = fileSHA256(exeFile); err != nil {
+            return res, err
+        }
+    }
+
+    if opts.RawBinary {
+        if err := placeRawBinary(exeFile, res); err != nil {
+            return res, err
+        }
+    } else {
+        release, err := acquire(ctx, compressSem)
+        if err != nil {
+            return res, err
+        }
+        start := time.Now()
+        err = writeCompressed(outFile, func(part string) error {
+            return compressFile(exeFile, part, platform)
+        })
+        res.CompressTime = time.Since(start)
+        release()
+        if err != nil {
+            return res, &CompressError{Platform: platform, URL: res.URL, Err: err}
+        }
+        os.Remove(exeFile)
+    }
+    return res, finishTarget(res)
+}
+
+// 先写 <output>.part 再改名，避免中断时留下半截输出
+func writeAtomic(output string, write func(part string) error) error {
+    part := output + ".part"
+    if err := write(part); err != nil {
+        os.Remove(part)
         return err
     }
-    tr := tar.NewReader(xzr)
+    return os.Rename(part, output)
+}
 
-    for {
-        h, err := tr.Next()
-        if err == io.EOF {
-            break
+// 输出写好后的收尾: 计算输出哈希、写出各类附属文件、调整属主并记下待上传的文件
+func finishTarget(res *targetResult) error {
+    if info, err := os.Stat(res.OutFile); err == nil {
+        res.OutputBytes = info.Size()
+    }
+    if err := chownOutput(res.OutFile); err != nil {
+        return err
+    }
+    files := []string{res.OutFile}
+    if opts.AlsoGzip && !opts.RawBinary {
+        side := gzipSidecar(res.OutFile)
+        if info, err := os.Stat(side); err == nil {
+            res.SidecarBytes = info.Size()
         }
+        if err := chownOutput(side); err != nil {
+            return err
+        }
+        files = append(files, side)
+        logf(levelPhase, "📦 [%s] %s %s，.gz %s\n", res.Platform,
+            compressors[opts.Format].Ext, formatBytes(res.OutputBytes), formatBytes(res.SidecarBytes))
+    }
+    // 流式处理时已在写出的同时算好
+    if (opts.Provenance || opts.SidecarMeta) && res.OutputSHA256 == "" {
+        sum, err := fileSHA256(res.OutFile)
         if err != nil {
             return err
         }
-        if strings.HasSuffix(h.Name, "/bin/node") {
-            out, err := os.Create(outFile)
-            if err != nil {
-                return err
-            }
-            defer out.Close()
-
-            _, err = io.Copy(out, tr)
-            fmt.Printf("解压[%s] bin/node 完成\n", platform)
+        res.OutputSHA256 = sum
+    }
+    if opts.SidecarMeta {
+        if err := writeSidecarMeta(res); err != nil {
+            return err
+        }
+        if err := chownOutput(metaPath(res.OutFile)); err != nil {
             return err
         }
+        files = append(files, metaPath(res.OutFile))
     }
-    return fmt.Errorf("未找到 bin/node")
+    if opts.Provenance {
+        if err := writeProvenance(res); err != nil {
+            return err
+        }
+        if err := chownOutput(res.OutFile + ".provenance.json"); err != nil {
+            return err
+        }
+        files = append(files, res.OutFile+".provenance.json")
+    }
+    // 版本目录发布后才上传，见 uploadVersion
+    if s3Store != nil {
+        res.Uploads = files
+    }
+    return nil
 }
 
-func compressZstd(input, output, platform string) error {
-    in, err := os.Open(input)
+func extractNodeFromZip(zipPath, outFile, platform string) error {
+    f, err := os.Open(zipPath)
     if err != nil {
         return err
     }
-    defer in.Close()
-
-    info, _ := in.Stat()
-    out, err := os.Create(output)
+    defer f.Close()
+    info, err := f.Stat()
     if err != nil {
         return err
     }
-    defer out.Close()
+    return extractToFile(outFile, platform, func(out io.Writer) error {
+        if err := ExtractNodeZip(f, info.Size(), out, extractOptions()); err != nil {
+            return err
+        }
+        logf(levelPhase, "\r解压[%s] node.exe 完成\n", platform)
+        return nil
+    })
+}
 
-    enc, err := zstd.NewWriter(out)
+// -node-path-regex 编译结果，为 nil 时使用默认的 /bin/node 后缀匹配
+var nodePathRe *regexp.Regexp
+
+// 按命令行参数生成解压设置，ExtractNode 等不直接读取 opts
+// -max-extract-size 0 表示不限制，对应 MaxSize 为负数
+func extractOptions() ExtractOptions {
+    o := ExtractOptions{
+        MaxSize:        opts.MaxExtractSize,
+        NodePath:       nodePathRe,
+        NodeByBasename: opts.NodeByBasename,
+        ZipRecover:     opts.ZipRecover,
+    }
+    if o.MaxSize == 0 {
+        o.MaxSize = -1
+    }
+    return o
+}
+
+func extractNodeFromTar(tarPath, outFile, platform string) error {
+    f, err := os.Open(tarPath)
     if err != nil {
         return err
     }
-    defer enc.Close()
+    defer f.Close()
 
-    pw := &ProgressWriter{Total: info.Size(), Prefix: "压缩[" + platform + "]"}
-    _, err = io.Copy(enc, io.TeeReader(in, pw))
-    fmt.Printf("\r压缩[%s] 100%%\n", platform)
-    return err
+    return extractToFile(outFile, platform, func(out io.Writer) error {
+        if err := ExtractNode(f, archiveFormat(platform), out, extractOptions()); err != nil {
+            return err
+        }
+        logf(levelPhase, "\r解压[%s] bin/node 完成\n", platform)
+        return nil
+    })
+}
+
+// 创建 outFile 并交给 extract 写入，找不到成员时不留下空文件
+// 写入时按已解压的字节数显示进度 (成员大小在解压前未知)
+func extractToFile(outFile, platform string, extract func(io.Writer) error) error {
+    out, err := os.Create(outFile)
+    if err != nil {
+        return err
+    }
+    pw := &ProgressWriter{Phase: PhaseExtract, Subject: platform}
+    if err := extract(io.MultiWriter(out, pw)); err != nil {
+        out.Close()
+        os.Remove(outFile)
+        return err
+    }
+    return out.Close()
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+    "runtime"
+
+    "github.com/klauspost/compress/zstd"
+)
+
+// zstd 编码器参数，-max-memory 可能调低
+var (
+    zstdWindow  = defaultZstdWindow
+    zstdThreads = runtime.GOMAXPROCS(0)
+)
+
+const (
+    defaultZstdWindow = 8 << 20 // 与 klauspost/zstd 默认窗口一致，仅用于估计；最快级别实际默认为 4MB
+    minZstdWindow     = 1 << 20
+)
+
+// 各级别每个编码线程的匹配表开销，粗略估计
+var zstdLevelTable = map[zstd.EncoderLevel]int64{
+    zstd.SpeedFastest:           1 << 20,
+    zstd.SpeedDefault:           2 << 20,
+    zstd.SpeedBetterCompression: 8 << 20,
+    zstd.SpeedBestCompression:   32 << 20,
+}
+
+// 单个 zstd 编码器的内存估计: 线程数 × (两倍窗口 + 匹配表)
+// 各目标级别不同时按开销最大的级别估计
+func zstdEncoderMemory(window, threads int) int64 {
+    var table int64
+    for _, platform := range targets {
+        _, level := zstd.EncoderLevelFromString(zstdLevelFor(platform))
+        table = max(table, zstdLevelTable[level])
+    }
+    return int64(threads) * (2*int64(window) + table)
+}
+
+// 按 -max-memory 收紧压缩参数 (估计值 = 级别开销 × 编码线程 × 同时压缩的目标数): 依次降低压缩并发、编码线程数、窗口大小，直到估计值不超过预算
+// 只对 zstd 生效，brotli 为单线程且内存占用较小
+func applyMemoryBudget(numTargets int) {
+    if opts.MaxMemory <= 0 || opts.Format != "zstd" {
+        return
+    }
+    // 同时压缩的编码器数不会超过目标数
+    opts.CompressConcurrency = min(opts.CompressConcurrency, max(numTargets, 1))
+    estimate := func() int64 {
+        return int64(opts.CompressConcurrency) * zstdEncoderMemory(zstdWindow, zstdThreads)
+    }
+    before := estimate()
+    if before <= opts.MaxMemory {
+        return
+    }
+    for estimate() > opts.MaxMemory && opts.CompressConcurrency > 1 {
+        opts.CompressConcurrency--
+    }
+    for estimate() > opts.MaxMemory && zstdThreads > 1 {
+        zstdThreads--
+    }
+    for estimate() > opts.MaxMemory && zstdWindow > minZstdWindow {
+        zstdWindow /= 2
+    }
+
+    logf(levelSummary, "⚠️  -max-memory %s: 压缩内存估计 %s -> %s (压缩并发 %d，编码线程 %d，窗口 %s)\n",
+        formatBytes(opts.MaxMemory), formatBytes(before), formatBytes(estimate()),
+        opts.CompressConcurrency, zstdThreads, formatBytes(int64(zstdWindow)))
+    if estimate() > opts.MaxMemory {
+        logf(levelError, "⚠️  已降到最低设置仍超出 -max-memory，可改用更低的 -zstd-level\n")
+    }
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+
+    "golang.org/x/sync/singleflight"
+)
+
+// 版本索引的内存缓存: -versions 批量构建时所有版本共用一份 index.json，常驻模式每轮清空
+// SHASUMS256.txt 按地址 (即按版本和 dist 根地址) 缓存在 remoteSums 中，与索引同时清空
+var (
+    metaFlight singleflight.Group
+    indexMu    sync.Mutex
+    indexCache []NodeVersion
+)
+
+// 返回 index.json，已缓存时直接返回；多个调用方同时请求时只发一次请求，结果共享
+// 获取失败不缓存，下次调用重新请求
+func loadIndex(ctx context.Context) ([]NodeVersion, error) {
+    indexMu.Lock()
+    cached := indexCache
+    indexMu.Unlock()
+    if cached != nil {
+        return cached, nil
+    }
+    v, err, _ := metaFlight.Do("index", func() (any, error) {
+        versions, err := fetchIndex(ctx)
+        if err != nil {
+            stale, ok := staleIndex(err)
+            if !ok {
+                return nil, err
+            }
+            versions = stale
+        } else if opts.IndexStaleOK {
+            saveIndexCache(versions)
+        }
+        indexMu.Lock()
+        indexCache = versions
+        indexMu.Unlock()
+        return versions, nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return v.([]NodeVersion), nil
+}
+
+// 清空索引和 SHASUMS256.txt 的缓存，常驻模式每轮开始时调用以获取最新发布
+func resetMetadataCache() {
+    indexMu.Lock()
+    indexCache = nil
+    indexMu.Unlock()
+    resetRemoteSums()
+}
+
+// -index-stale-ok 的磁盘缓存: 每次成功获取 index.json 后写到用户缓存目录，按索引地址区分，
+// 索引被限流 (429/503) 时退回到不超过 -index-max-stale 的缓存；失败只给出提示，不影响构建
+func indexCachePath() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    sum := sha256.Sum256([]byte(releaseSource.IndexURL()))
+    return filepath.Join(dir, "update-node", "index-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func saveIndexCache(versions []NodeVersion) {
+    path, err := indexCachePath()
+    if err == nil {
+        err = os.MkdirAll(filepath.Dir(path), 0o755)
+    }
+    if err == nil {
+        err = writeAtomic(path, func(part string) error {
+            return writeJSONFile(part, versions)
+        })
+    }
+    if err != nil {
+        logf(levelPhase, "⚠️  写入 index.json 缓存失败: %v\n", err)
+    }
+}
+
+// 只有限流类响应才退回缓存；网络错误、404 等说明配置或环境有问题，照常失败
+func staleIndex(fetchErr error) ([]NodeVersion, bool) {
+    var se *httpStatusError
+    if !opts.IndexStaleOK || !errors.As(fetchErr, &se) ||
+        (se.StatusCode != http.StatusTooManyRequests && se.StatusCode != http.StatusServiceUnavailable) {
+        return nil, false
+    }
+    path, err := indexCachePath()
+    if err != nil {
+        return nil, false
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        logf(levelError, "⚠️  index.json 返回 %v，但没有可用的缓存\n", se)
+        return nil, false
+    }
+    age := time.Since(info.ModTime())
+    if age > opts.IndexMaxStale {
+        logf(levelError, "⚠️  index.json 返回 %v，缓存已有 %s，超过 -index-max-stale %s，不使用\n",
+            se, age.Round(time.Minute), opts.IndexMaxStale)
+        return nil, false
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, false
+    }
+    var versions []NodeVersion
+    if err := json.Unmarshal(data, &versions); err != nil || len(versions) == 0 {
+        logf(levelError, "⚠️  index.json 缓存 %s 无法解析，不使用\n", path)
+        return nil, false
+    }
+    logf(levelError, "⚠️  index.json 返回 %v，改用 %s 前缓存的索引 (%s)，可能缺少此后的新发布\n",
+        se, age.Round(time.Minute), path)
+    return versions, true
+}
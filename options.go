@@ -0,0 +1,731 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "runtime"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Options 汇总所有命令行参数
+type Options struct {
+    Config string
+
+    Version   string
+    Versions  []string
+    Channel   string
+    LTSName   string
+
+    AllowPrereleaseLTS bool
+    SourceDir string
+
+    VersionRange string
+    SinceDate    string
+    Since        time.Time
+    MaxAge       time.Duration
+    Strict       bool
+
+    Checksum     bool
+    ChecksumMode string
+    VerifyGPG    bool
+    GPGKeyring   string
+
+    SignKey        string
+    SignPassphrase string
+
+    Mirror    string
+
+    MirrorPreset string
+    CrossCheckMirrors []string
+    IndexURL     string
+    IndexStaleOK  bool
+    IndexMaxStale time.Duration
+
+    ArchiveTemplate string
+    ReleasePath     string
+
+    Source            string
+    GitHubRepo        string
+    GitHubTagTemplate string
+
+    Socks5        string
+    BindIP        string
+    HTTPTrace     string
+    DialTimeout   time.Duration
+    TLSTimeout    time.Duration
+    HeaderTimeout time.Duration
+    MinSpeed      int64 // 字节/秒，0 表示不检测停滞
+    StallWindow   time.Duration
+    Retries       int
+    RetryRate     float64
+    Extra      []string
+    ExtractDir string
+
+    NodePathRegex  string
+    NodeByBasename bool
+    TargetsFile string
+    Platforms []string
+    GoPlatforms []string
+    PlatformsPreset string
+    Only      string
+    Host      bool
+    Prefix    string
+    Out       string
+    TmpDir    string
+    ReplaceAtomic bool
+
+    SummaryOnly bool
+    NoColor     bool
+    ForceColor  bool
+    RawBinary   bool
+    ZipRecover  bool
+
+    WindowsExeName string
+
+    Format          string
+    ZstdLevel       string
+    ZstdLevelByArch map[string]string
+    ZstdDict        string
+    BrotliQuality   int
+    AlsoGzip        bool
+
+    FailFast         bool
+    MaxFailures      int
+    Provenance       bool
+    SidecarMeta      bool
+    EmitSRI          string
+    ReportHTML       string
+    SpeedSamples     bool
+    SpeedSamplesJSON string
+    Dedupe           bool
+
+    VerifyVersion  bool
+    CheckUpdate    bool
+    RunState       string
+    VerifyOnly     bool
+    RefreshMeta    bool
+    Prune          bool
+    Keep           int
+    CompressOnly   string
+    ChecksumFormat string
+    ChecksumAlgo   string
+    DumpURLs       bool
+    DumpFormat     string
+    PrintConfig    bool
+    DryRun         bool
+    SelfTest       bool
+    Benchmark      bool
+    BenchmarkJSON  bool
+    BenchmarkSize  int64
+    AssumeSpeed    int64 // 字节/秒，0 表示实测
+
+    S3Bucket    string
+    S3Prefix    string
+    S3Endpoint  string
+    S3Region    string
+    S3PathStyle bool
+    S3Meta      map[string]string
+
+    Deadline   time.Duration
+    Interval   time.Duration
+    HealthAddr string
+
+    Chown    string
+    ChownUID int // 未设置 -chown 时为 -1
+    ChownGID int
+
+    Concurrency         string
+    DownloadConcurrency int
+    ExtractConcurrency  int
+    CompressConcurrency int
+    AdaptiveConcurrency bool
+    Segments            int // 单个压缩包的并发连接数，<= 1 为单连接
+    VersionConcurrency  int // -versions 同时构建的版本数
+
+    MaxMemory      int64 // 字节，0 表示不限制
+    MaxExtractSize int64 // 单个成员解压后的上限，0 表示不限制
+
+    MinFreeSpace   int64 // 字节，0 表示不检查可用空间 (inode 仍检查)
+    SpaceWait      time.Duration
+    SkipSpaceCheck bool
+}
+
+var opts Options
+
+// node 可执行文件约 100 MB，留足余量
+const defaultMaxExtractSize = 1 << 30
+
+// 解析命令行参数，出错时由 flag 包输出用法说明
+func parseFlags() error {
+    flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+    flag.StringVar(&opts.Config, "config", "", "从 YAML (.yaml/.yml) 或 TOML (.toml) 文件读取参数，键为参数名；命令行参数优先")
+    flag.StringVar(&opts.Version, "version", "", "指定 Node 版本，如 v20.11.0 (可省略 v)，默认最新 LTS")
+    flag.Func("versions", "依次构建多个版本，逗号分隔，如 v18.20.0,v20.11.0，输出到 -out/<版本>/；all 表示按 -since-date/-version-range 等条件从 index.json 选出全部版本", func(v string) error {
+        opts.Versions = splitList(v)
+        return nil
+    })
+    flag.StringVar(&opts.Channel, "channel", "lts", "版本通道: lts 只选 LTS，current 选最新版本")
+    flag.StringVar(&opts.LTSName, "lts-name", "", "按 LTS 代号选择，如 iron")
+    flag.BoolVar(&opts.AllowPrereleaseLTS, "allow-prerelease-lts", false, "配合 -lts-name: 该代号主版本中尚未标记 LTS 的版本也可选中 (可能选到仍在 Current 阶段的版本)")
+    flag.StringVar(&opts.SinceDate, "since-date", "", "只选该日期 (YYYY-MM-DD) 及之后发布的版本，按 index.json 的 date 字段")
+    flag.Func("max-age", "选出的版本发布超过这么久时警告 (镜像或 index.json 可能已过期)，如 90d", func(v string) error {
+        d, err := parseAge(v)
+        opts.MaxAge = d
+        return err
+    })
+    flag.BoolVar(&opts.Strict, "strict", false, "把 -max-age 的警告视为失败")
+    flag.StringVar(&opts.VersionRange, "version-range", "", "按 semver 范围选择，如 20.x 或 \">=18 <22\"")
+    flag.StringVar(&opts.SourceDir, "source-dir", "", "从本地目录读取预先下载的 node-<version>-<platform>.{tar.xz,zip}，不访问网络")
+    flag.BoolVar(&opts.Checksum, "checksum", true, "按上游 SHASUMS256.txt 校验下载的压缩包，-checksum=false 等同 -checksum-mode off")
+    flag.StringVar(&opts.ChecksumMode, "checksum-mode", checksumIfPresent, "校验策略: required 未列出即失败，if-present 列出时校验，off 不校验")
+    flag.BoolVar(&opts.VerifyGPG, "verify-gpg", false, "改用签名版 SHASUMS256.txt.asc，按 -gpg-keyring 校验签名，缺失或无效即失败")
+    flag.StringVar(&opts.GPGKeyring, "gpg-keyring", "", "-verify-gpg 使用的发布者公钥文件 (armor 或二进制)")
+    flag.StringVar(&opts.SignKey, "sign-key", "", "用该私钥文件 (armor 或二进制) 为写出的输出校验和文件生成签名版 <文件>.asc")
+    flag.StringVar(&opts.SignPassphrase, "sign-passphrase", "", "-sign-key 私钥的密码，建议改用 "+envName("sign-passphrase")+" 环境变量，避免出现在进程列表中")
+    flag.StringVar(&opts.Mirror, "mirror", defaultMirror, "下载镜像地址，index.json 与各版本目录位于其下")
+    flag.StringVar(&opts.MirrorPreset, "mirror-preset", "", "镜像预设: nodejs、taobao (npmmirror)、tuna，同时设置 dist 和 index.json 地址")
+    flag.Func("cross-check-mirrors", "校验和不匹配时依次从这些镜像 (dist 根地址，逗号分隔) 重新下载对比，区分镜像损坏和校验和问题", func(v string) error {
+        opts.CrossCheckMirrors = splitList(v)
+        return nil
+    })
+    flag.StringVar(&opts.IndexURL, "index-url", "", "index.json 地址，默认为 <mirror>/index.json")
+    flag.BoolVar(&opts.IndexStaleOK, "index-stale-ok", false, "缓存每次获取的 index.json，之后获取时遇到 429/503 限流改用不超过 -index-max-stale 的缓存并警告")
+    flag.DurationVar(&opts.IndexMaxStale, "index-max-stale", 24*time.Hour, "-index-stale-ok 可接受的缓存最长时间")
+    flag.StringVar(&opts.ArchiveTemplate, "archive-template", defaultArchiveTemplate, "压缩包文件名模板，可用 {{.Version}} {{.Platform}} {{.Ext}}")
+    flag.StringVar(&opts.ReleasePath, "release-path", "", "镜像根地址与版本目录之间的路径，如 releases 表示 <mirror>/releases/vX.Y.Z/")
+    flag.StringVar(&opts.Source, "source", "nodejs", "发布来源: nodejs (官方 dist 布局，可配合 -mirror)、npmmirror、unofficial (unofficial-builds，musl 等)、github (GitHub Releases 附件)")
+    flag.StringVar(&opts.GitHubRepo, "github-repo", "", "-source github 的仓库 OWNER/REPO")
+    flag.StringVar(&opts.GitHubTagTemplate, "github-tag-template", defaultGitHubTagTemplate, "-source github 的 release tag 模板，可用 {{.Version}}")
+    flag.StringVar(&opts.Socks5, "socks5", "", "SOCKS5 代理地址 HOST:PORT (同时设置 HTTP 代理时 SOCKS5 优先)")
+    flag.StringVar(&opts.BindIP, "bind-ip", "", "出站连接使用的本机源 IP，用于多出口主机")
+    flag.StringVar(&opts.HTTPTrace, "http-trace", "", "把每个 HTTP 请求 (方法、地址、状态、字节数、耗时) 以 JSON Lines 追加写入该文件，凭据类请求头会被隐去")
+    flag.DurationVar(&opts.DialTimeout, "dial-timeout", 30*time.Second, "建立 TCP 连接的超时，0 表示不限制")
+    flag.DurationVar(&opts.TLSTimeout, "tls-timeout", 10*time.Second, "TLS 握手的超时，0 表示不限制")
+    flag.DurationVar(&opts.HeaderTimeout, "response-header-timeout", 30*time.Second, "发出请求后等待响应头的超时，不限制响应体的传输时间，0 表示不限制")
+    flag.Func("min-speed", "下载速度在 -stall-window 内一直低于该值 (每秒字节数，如 50KB) 时视为停滞，中断并重试；默认不检测", func(v string) error {
+        n, err := parseSize(v)
+        opts.MinSpeed = n
+        return err
+    })
+    flag.DurationVar(&opts.StallWindow, "stall-window", 30*time.Second, "停滞检测的时间窗口")
+    flag.IntVar(&opts.Retries, "retries", 3, "每个目标下载失败后的最大重试次数")
+    flag.Float64Var(&opts.RetryRate, "retry-rate", 0.5, "所有目标共享的重试速率上限 (次/秒)，0 表示不限制")
+    flag.Func("extra", "额外打包的包内路径或 glob，逗号分隔，如 include/node/,LICENSE", func(v string) error {
+        opts.Extra = append(opts.Extra, splitList(v)...)
+        return nil
+    })
+    flag.StringVar(&opts.TargetsFile, "targets-file", "", "从 JSON 文件读取目标列表替换内置列表，可按目标覆盖 baseURL/retries/timeout/zstdLevel")
+    flag.Func("platforms", "只构建指定平台，逗号分隔，如 linux-x64,win-x64", func(v string) error {
+        opts.Platforms = append(opts.Platforms, splitList(v)...)
+        return nil
+    })
+    flag.Func("platforms-from-go", "用 GOOS/GOARCH 指定平台，逗号分隔，如 linux/amd64,windows/arm64", func(v string) error {
+        opts.GoPlatforms = append(opts.GoPlatforms, splitList(v)...)
+        return nil
+    })
+    flag.StringVar(&opts.PlatformsPreset, "platforms-preset", "", "常用平台分组: desktop (macOS/Windows x64、arm64)、server (Linux x64、arm64)、all-linux、ci (当前机器)，可与 -platforms 合并")
+    flag.StringVar(&opts.Only, "only", "", "只构建单个平台，顺序执行并输出单行进度")
+    flag.BoolVar(&opts.Host, "host", false, "只构建与当前机器 GOOS/GOARCH 对应的平台")
+    flag.StringVar(&opts.WindowsExeName, "windows-exe-name", "keep", "Windows 输出解压后的文件名: keep 为 node.exe，strip 为 node；记录在元数据的 originalName 中")
+    flag.BoolVar(&opts.ZipRecover, "zip-recover", false, "zip 中央目录损坏时扫描本地文件头尽力恢复 node.exe (只用于提取 node.exe，建议之后重新下载)")
+    flag.StringVar(&opts.ExtractDir, "extract-dir", "", "把包内整个目录 (如 bin) 或 glob 匹配的成员打包为 tar 后压缩")
+    flag.StringVar(&opts.NodePathRegex, "node-path-regex", "", "用正则匹配 tar 包内的 node 可执行文件路径，默认匹配 /bin/node 结尾")
+    flag.BoolVar(&opts.NodeByBasename, "node-by-basename", false, "不按路径匹配，改为在任意目录中查找文件名为 node/node.exe 的普通文件，恰好一个时使用，零个或多个时失败")
+    flag.StringVar(&opts.Out, "out", ".", "输出目录，不存在时自动创建")
+    flag.StringVar(&opts.TmpDir, "tmp-dir", "", "下载和解压临时文件目录，默认与 -out 相同")
+    flag.StringVar(&opts.Prefix, "prefix", "", "所有输出文件名的前缀，如 current_")
+    flag.StringVar(&opts.Concurrency, "concurrency", "", "同时设置下载、解压和压缩并发数: 数字或 auto")
+    flag.IntVar(&opts.DownloadConcurrency, "concurrency-downloads", 3, "同时下载的最大目标数")
+    flag.IntVar(&opts.ExtractConcurrency, "extract-concurrency", runtime.GOMAXPROCS(0), "同时解压的最大目标数 (每个解压中的目标持有一个打开的压缩包)")
+    flag.IntVar(&opts.CompressConcurrency, "concurrency-compress", runtime.GOMAXPROCS(0), "同时压缩的最大目标数")
+    flag.BoolVar(&opts.SpeedSamples, "speed-samples", false, "下载时按进度刷新间隔采样速度，结束时输出每个目标的最小/中位数/p95/最大速度")
+    flag.StringVar(&opts.SpeedSamplesJSON, "speed-samples-json", "", "把 -speed-samples 的分布和全部样本写到该 JSON 文件 (隐含 -speed-samples)")
+    flag.BoolVar(&opts.AdaptiveConcurrency, "adaptive-concurrency", false, "下载并发从 1 开始，成功时逐步增加、失败时减半，上限为 -concurrency-downloads")
+    flag.IntVar(&opts.VersionConcurrency, "version-concurrency", 1, "-versions 同时构建的版本数；各阶段的并发上限由所有版本共享，总并发不随版本数增加")
+    flag.IntVar(&opts.Segments, "segments", 0, "每个压缩包用 N 个连接分段并发下载 (需服务器支持 Range，否则退回单连接)，会关闭流式处理")
+    flag.Func("max-memory", "压缩内存预算，如 512MB、2GB，超出时自动降低压缩并发和 zstd 窗口", func(v string) error {
+        n, err := parseSize(v)
+        opts.MaxMemory = n
+        return err
+    })
+    opts.MaxExtractSize = defaultMaxExtractSize
+    flag.Func("max-extract-size", "单个压缩包成员解压后的大小上限，如 2GB，超出时拒绝 (防解压炸弹)，默认 1GB，0 表示不限制", func(v string) error {
+        n, err := parseSize(v)
+        opts.MaxExtractSize = n
+        return err
+    })
+    flag.Func("min-free-space", "下载前要求 -out 和 -tmp-dir 至少有这么多可用空间，如 2GB", func(v string) error {
+        n, err := parseSize(v)
+        opts.MinFreeSpace = n
+        return err
+    })
+    flag.DurationVar(&opts.SpaceWait, "space-wait", 0, "空间不足时最多等待多久再失败，如 10m，期间定期重新检查；0 表示立即失败")
+    flag.BoolVar(&opts.SkipSpaceCheck, "skip-space-check", false, "下载前不检查 -out 和 -tmp-dir 的可用空间和剩余 inode")
+    flag.BoolVar(&opts.SummaryOnly, "summary-only", false, "只输出每个目标的结果行和最终汇总，隐藏各阶段日志")
+    flag.StringVar(&opts.Format, "format", "zstd", "输出压缩格式: zstd (.zst)、brotli (.br) 或 gzip (.gz)")
+    flag.StringVar(&opts.ZstdLevel, "zstd-level", "default", "zstd 压缩级别: fastest、default、better、best")
+    flag.Func("zstd-level-by-arch", "按 CPU 架构覆盖 zstd 级别，如 x64=best,armv7l=default，可重复，未列出的架构沿用 -zstd-level", func(v string) error {
+        if opts.ZstdLevelByArch == nil {
+            opts.ZstdLevelByArch = map[string]string{}
+        }
+        for _, item := range splitList(v) {
+            arch, level, ok := strings.Cut(item, "=")
+            arch, level = strings.TrimSpace(arch), strings.TrimSpace(level)
+            if !ok || arch == "" || level == "" {
+                return fmt.Errorf("格式应为 ARCH=LEVEL: %q", item)
+            }
+            if _, dup := opts.ZstdLevelByArch[arch]; dup {
+                return fmt.Errorf("重复的架构: %s", arch)
+            }
+            opts.ZstdLevelByArch[arch] = level
+        }
+        return nil
+    })
+    flag.StringVar(&opts.ZstdDict, "zstd-dict", "", "zstd 字典文件: 存在时用于所有输出；不存在时 -versions 批量模式会先抽样训练并写到这里")
+    flag.IntVar(&opts.BrotliQuality, "brotli-quality", 9, "brotli 压缩质量 0-11")
+    flag.BoolVar(&opts.NoColor, "no-color", false, "关闭进度刷新等终端控制字符 (也可设置 NO_COLOR)")
+    flag.BoolVar(&opts.ForceColor, "force-color", false, "即使输出不是终端也保留进度刷新")
+    flag.BoolVar(&opts.AlsoGzip, "also-gzip", false, "同时输出一份 .gz，与主输出共用一次解压")
+    flag.BoolVar(&opts.RawBinary, "raw-binary", false, "直接输出解压后的 node 可执行文件，不压缩")
+    flag.BoolVar(&opts.FailFast, "fail-fast", false, "任一目标失败时立即终止其余目标")
+    flag.IntVar(&opts.MaxFailures, "max-failures", 0, "真正的失败 (不含 404) 超过 N 个时终止整个任务，0 表示不限制")
+    flag.BoolVar(&opts.Provenance, "provenance", false, "为每个输出写出 <output>.provenance.json 来源证明")
+    flag.BoolVar(&opts.SidecarMeta, "sidecar-meta", false, "为每个输出写出 <output>.meta，记录源压缩包名、版本、平台和两端的 SHA-256")
+    flag.StringVar(&opts.ReportHTML, "report-html", "", "运行结束后把各目标的状态、大小、压缩比、耗时和校验和写成自包含的 HTML 报告")
+    flag.StringVar(&opts.EmitSRI, "emit-sri", "", "把每个源压缩包的地址和 SRI 哈希 (sha256-<base64>) 写入该文件，- 表示标准输出")
+    flag.BoolVar(&opts.VerifyVersion, "verify-version", false, "读取解压出的可执行文件内嵌的版本号，与期望版本不一致时警告")
+    flag.BoolVar(&opts.Dedupe, "dedupe", false, "结束后检查不同平台是否产出了完全相同的可执行文件")
+    flag.BoolVar(&opts.CheckUpdate, "check-update", false, "检查本工具在 GitHub 上是否有新版本 (只提示，不自动更新)")
+    flag.StringVar(&opts.RunState, "run-state", "", "记录已完成目标的状态文件，中断后重新运行会跳过已完成的目标")
+    flag.BoolVar(&opts.VerifyOnly, "verify-only", false, "只校验 -out 目录中已有的输出 (按其中的 SHASUMS256.txt)，不下载不构建")
+    flag.BoolVar(&opts.RefreshMeta, "refresh-metadata", false, "按 -out 中现有的输出重写校验和文件、.meta 和 versions.json，不下载不构建")
+    flag.BoolVar(&opts.Prune, "prune", false, "按版本号只保留 -out 下最新的 -keep 个 <version>/ 子目录，删除其余的，可配合 -dry-run 预览")
+    flag.IntVar(&opts.Keep, "keep", 3, "-prune 保留的版本目录数")
+    flag.StringVar(&opts.S3Bucket, "s3-bucket", "", "构建后把输出上传到此 S3 存储桶，凭据读取 AWS_ACCESS_KEY_ID 等标准环境变量")
+    flag.StringVar(&opts.S3Prefix, "s3-prefix", "", "上传对象键的前缀，如 releases/node")
+    flag.StringVar(&opts.S3Endpoint, "s3-endpoint", "", "S3 兼容存储地址，如 MinIO 的 http://127.0.0.1:9000")
+    flag.StringVar(&opts.S3Region, "s3-region", "", "S3 区域，默认读取 AWS_REGION")
+    flag.BoolVar(&opts.S3PathStyle, "s3-path-style", false, "使用 path-style 地址 (MinIO 等通常需要)")
+    flag.Func("s3-meta", "上传对象的自定义元数据 key=val，可重复", func(v string) error {
+        k, val, ok := strings.Cut(v, "=")
+        if !ok || k == "" {
+            return fmt.Errorf("格式应为 key=val: %q", v)
+        }
+        if opts.S3Meta == nil {
+            opts.S3Meta = map[string]string{}
+        }
+        opts.S3Meta[strings.ToLower(k)] = val
+        return nil
+    })
+    flag.BoolVar(&opts.ReplaceAtomic, "replace-existing-atomic", false, "先构建到暂存目录，全部目标成功后才把 -out 原子地切换过去 (Unix 上 -out 变为符号链接)")
+    flag.StringVar(&opts.CompressOnly, "compress-only", "", "只把 DIR 中已有的 node 可执行文件按当前格式压缩到 -out 并写出 SHASUMS256.txt，不下载不解压")
+    flag.StringVar(&opts.ChecksumFormat, "checksum-format", "gnu", "构建、-compress-only、-refresh-metadata 写出的输出校验和文件格式: gnu (<hash>  <file>，与 sha256sum 和 Node 官方一致) 或 bsd (SHA256 (<file>) = <hash>)")
+    flag.StringVar(&opts.ChecksumAlgo, "checksum-algo", "sha256", "构建、-compress-only 写出和 -verify-only 读取的输出校验和算法: sha256 (SHASUMS256.txt)、sha512 (SHA512SUMS) 或 blake3 (B3SUMS)；源压缩包始终按 SHA-256 校验")
+    flag.BoolVar(&opts.DumpURLs, "dump-urls", false, "只解析版本并输出各平台压缩包地址和 SHASUMS256.txt 地址，不下载")
+    flag.StringVar(&opts.DumpFormat, "dump-format", "text", "-dump-urls 和 -print-config 的输出格式: text 每行一个地址/一项设置，json 按平台/按设置输出")
+    flag.BoolVar(&opts.PrintConfig, "print-config", false, "输出所有生效设置 (凭据已隐去) 后退出，格式由 -dump-format 决定")
+    flag.BoolVar(&opts.DryRun, "dry-run", false, "只用 HEAD 请求汇总各版本各平台的下载量并估算耗时，不下载")
+    flag.BoolVar(&opts.SelfTest, "selftest", false, "用内置夹具离线跑一遍下载校验、解压、压缩和解压回读，检查当前二进制和环境是否正常，失败时退出码非 0")
+    flag.BoolVar(&opts.Benchmark, "benchmark", false, "依次从各镜像预设、当前镜像和 -cross-check-mirrors 下载同一压缩包的开头一段，按速度和延迟排名后退出")
+    flag.BoolVar(&opts.BenchmarkJSON, "benchmark-json", false, "以 JSON 输出 -benchmark 的排名 (隐含 -benchmark)，便于脚本选择镜像")
+    opts.BenchmarkSize = defaultBenchmarkSize
+    flag.Func("benchmark-size", "-benchmark 每个镜像下载的字节数，默认 2MB，最大 64MB", func(v string) error {
+        n, err := parseSize(v)
+        opts.BenchmarkSize = n
+        return err
+    })
+    flag.Func("assume-speed", "-dry-run 估算耗时使用的下载速度 (每秒)，如 10MB；默认下载一小段实测", func(v string) error {
+        n, err := parseSize(v)
+        opts.AssumeSpeed = n
+        return err
+    })
+    flag.DurationVar(&opts.Deadline, "deadline", 0, "软性时限，如 5m: 到期后不再开始新目标，已开始的目标照常完成")
+    flag.DurationVar(&opts.Interval, "interval", 0, "常驻模式: 按此间隔反复执行完整流程，如 6h")
+    flag.StringVar(&opts.HealthAddr, "health-addr", "", "常驻模式下的健康检查地址，如 :8080，提供 /healthz 和 /status")
+    flag.StringVar(&opts.Chown, "chown", "", "写出后把输出文件属主改为 UID:GID (仅 Unix)")
+    if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+        return err
+    }
+    if err := loadConfigLayers(); err != nil {
+        fmt.Fprintf(flag.CommandLine.Output(), "%v\n", err)
+        return err
+    }
+
+    if opts.SummaryOnly {
+        verbosity = levelSummary
+    }
+    useANSI = shouldUseANSI()
+    if opts.DownloadConcurrency < 1 {
+        opts.DownloadConcurrency = 1
+    }
+    if opts.ExtractConcurrency < 1 {
+        opts.ExtractConcurrency = 1
+    }
+    if opts.CompressConcurrency < 1 {
+        opts.CompressConcurrency = 1
+    }
+    return nil
+}
+
+// 检查参数之间的冲突
+func validateOptions() error {
+    if opts.ExtractDir != "" {
+        // 目录按前缀匹配，含通配符时按 glob 匹配
+        pattern := opts.ExtractDir
+        if !strings.ContainsAny(pattern, "*?[") {
+            pattern = strings.TrimSuffix(pattern, "/") + "/"
+        }
+        opts.Extra = append(opts.Extra, pattern)
+    }
+    if opts.RawBinary && len(opts.Extra) > 0 {
+        return fmt.Errorf("-raw-binary 不能与 -extra/-extract-dir 同时使用")
+    }
+    if opts.CompressOnly != "" && (opts.RawBinary || opts.VerifyOnly || len(opts.Extra) > 0) {
+        return fmt.Errorf("-compress-only 不能与 -raw-binary、-verify-only、-extra/-extract-dir 同时使用")
+    }
+    if opts.ChecksumFormat != "gnu" && opts.ChecksumFormat != "bsd" {
+        return fmt.Errorf("-checksum-format 只能是 gnu 或 bsd: %q", opts.ChecksumFormat)
+    }
+    if opts.WindowsExeName != "keep" && opts.WindowsExeName != "strip" {
+        return fmt.Errorf("-windows-exe-name 只能是 keep 或 strip: %q", opts.WindowsExeName)
+    }
+    if _, ok := checksumAlgos[opts.ChecksumAlgo]; !ok {
+        return fmt.Errorf("-checksum-algo 只能是 sha256、sha512 或 blake3: %q", opts.ChecksumAlgo)
+    }
+    if opts.DumpFormat != "text" && opts.DumpFormat != "json" {
+        return fmt.Errorf("-dump-format 只能是 text 或 json: %q", opts.DumpFormat)
+    }
+    if opts.BenchmarkJSON {
+        opts.Benchmark = true
+    }
+    if opts.SpeedSamplesJSON != "" {
+        opts.SpeedSamples = true
+    }
+    if opts.Benchmark {
+        if opts.BenchmarkSize <= 0 || opts.BenchmarkSize > maxBenchmarkSize {
+            return fmt.Errorf("-benchmark-size 必须在 1 字节到 %s 之间", formatBytes(maxBenchmarkSize))
+        }
+        if opts.SourceDir != "" || opts.DryRun || opts.DumpURLs || opts.Interval > 0 || opts.VerifyOnly || opts.CompressOnly != "" {
+            return fmt.Errorf("-benchmark 不能与 -source-dir、-dry-run、-dump-urls、-interval、-verify-only、-compress-only 同时使用")
+        }
+    }
+    if opts.SelfTest && (opts.VerifyOnly || opts.CompressOnly != "" || opts.RefreshMeta || opts.DryRun || opts.DumpURLs || opts.Benchmark || opts.Interval > 0) {
+        return fmt.Errorf("-selftest 不能与 -verify-only、-compress-only、-refresh-metadata、-dry-run、-dump-urls、-benchmark、-interval 同时使用")
+    }
+    if opts.RefreshMeta && (opts.VerifyOnly || opts.CompressOnly != "" || opts.DryRun || opts.DumpURLs) {
+        return fmt.Errorf("-refresh-metadata 不能与 -verify-only、-compress-only、-dry-run、-dump-urls 同时使用")
+    }
+    if opts.Prune {
+        if opts.Keep < 1 {
+            return fmt.Errorf("-keep 必须大于 0: %d", opts.Keep)
+        }
+        if opts.VerifyOnly || opts.RefreshMeta || opts.CompressOnly != "" || opts.SelfTest || opts.DumpURLs || opts.Benchmark || opts.Interval > 0 || len(opts.Versions) > 0 {
+            return fmt.Errorf("-prune 不能与 -verify-only、-refresh-metadata、-compress-only、-selftest、-dump-urls、-benchmark、-interval、-versions 同时使用")
+        }
+    } else if explicitFlags()["keep"] {
+        return fmt.Errorf("-keep 需要配合 -prune 使用")
+    }
+    if opts.DryRun && (opts.SourceDir != "" || opts.Interval > 0 || opts.DumpURLs || opts.VerifyOnly || opts.CompressOnly != "") {
+        return fmt.Errorf("-dry-run 不能与 -source-dir、-interval、-dump-urls、-verify-only、-compress-only 同时使用")
+    }
+    if opts.DialTimeout < 0 || opts.TLSTimeout < 0 || opts.HeaderTimeout < 0 {
+        return fmt.Errorf("-dial-timeout、-tls-timeout、-response-header-timeout 不能为负数")
+    }
+    if opts.MinSpeed > 0 {
+        if opts.StallWindow <= 0 {
+            return fmt.Errorf("-stall-window 必须大于 0")
+        }
+    } else if explicitFlags()["stall-window"] {
+        return fmt.Errorf("-stall-window 需要配合 -min-speed 使用")
+    }
+    if opts.Segments < 0 || opts.Segments > 16 {
+        return fmt.Errorf("-segments 必须在 0 到 16 之间: %d", opts.Segments)
+    }
+    if opts.Segments > 1 && opts.SourceDir != "" {
+        return fmt.Errorf("-segments 不能与 -source-dir 同时使用")
+    }
+    if opts.ReplaceAtomic {
+        if opts.RunState != "" || opts.DryRun || opts.DumpURLs || opts.VerifyOnly || opts.CompressOnly != "" {
+            return fmt.Errorf("-replace-existing-atomic 不能与 -run-state、-dry-run、-dump-urls、-verify-only、-compress-only 同时使用")
+        }
+        opts.Out = filepath.Clean(opts.Out)
+        if out, err := filepath.Abs(opts.Out); err != nil || out == filepath.Dir(out) || opts.Out == "." {
+            return fmt.Errorf("-replace-existing-atomic 需要指定当前目录和根目录以外的 -out: %q", opts.Out)
+        }
+    }
+    if opts.AssumeSpeed > 0 && !opts.DryRun {
+        return fmt.Errorf("-assume-speed 需要配合 -dry-run 使用")
+    }
+    if opts.MaxFailures < 0 {
+        return fmt.Errorf("-max-failures 不能为负数")
+    }
+    if opts.SkipSpaceCheck && (opts.MinFreeSpace > 0 || opts.SpaceWait > 0) {
+        return fmt.Errorf("-skip-space-check 不能与 -min-free-space、-space-wait 同时使用")
+    }
+    if opts.HealthAddr != "" && opts.Interval <= 0 {
+        return fmt.Errorf("-health-addr 需要配合 -interval 使用")
+    }
+    explicit := explicitFlags()
+    switch opts.ChecksumMode {
+    case checksumRequired, checksumIfPresent, checksumOff:
+    default:
+        return fmt.Errorf("-checksum-mode 只能是 required、if-present 或 off: %q", opts.ChecksumMode)
+    }
+    // 兼容旧参数: 只给出 -checksum=false 时等同 -checksum-mode off
+    if explicit["checksum"] && !opts.Checksum && !explicit["checksum-mode"] {
+        opts.ChecksumMode = checksumOff
+    }
+    opts.Checksum = opts.ChecksumMode != checksumOff
+    if opts.VerifyGPG {
+        if !opts.Checksum {
+            return fmt.Errorf("-verify-gpg 不能与 -checksum-mode off 同时使用")
+        }
+        if opts.GPGKeyring == "" {
+            return fmt.Errorf("-verify-gpg 需要 -gpg-keyring 指定发布者公钥")
+        }
+        keys, err := loadGPGKeyring(opts.GPGKeyring)
+        if err != nil {
+            return fmt.Errorf("-gpg-keyring: %w", err)
+        }
+        gpgKeyring = keys
+    }
+    if opts.IndexStaleOK && opts.IndexMaxStale <= 0 {
+        return fmt.Errorf("-index-max-stale 必须大于 0: %s", opts.IndexMaxStale)
+    }
+    if explicit["index-max-stale"] && !opts.IndexStaleOK {
+        return fmt.Errorf("-index-max-stale 需要配合 -index-stale-ok 使用")
+    }
+    if opts.SignKey != "" {
+        if opts.CompressOnly == "" && !opts.RefreshMeta {
+            return fmt.Errorf("-sign-key 只对写出校验和文件的 -compress-only、-refresh-metadata 生效")
+        }
+        key, err := loadSigningKey(opts.SignKey, opts.SignPassphrase)
+        if err != nil {
+            return fmt.Errorf("-sign-key: %w", err)
+        }
+        signingKey = key
+    } else if opts.SignPassphrase != "" {
+        return fmt.Errorf("-sign-passphrase 需要配合 -sign-key 使用")
+    }
+    if err := validateFormat(); err != nil {
+        return err
+    }
+    if err := loadZstdDict(); err != nil {
+        return err
+    }
+    if err := applyMirrorPreset(explicit); err != nil {
+        return err
+    }
+    if err := initSource(explicit); err != nil {
+        return err
+    }
+    opts.ChownUID, opts.ChownGID = -1, -1
+    if opts.Chown != "" {
+        uid, gid, ok := strings.Cut(opts.Chown, ":")
+        u, err1 := strconv.Atoi(uid)
+        g, err2 := strconv.Atoi(gid)
+        if !ok || err1 != nil || err2 != nil || u < 0 || g < 0 {
+            return fmt.Errorf("-chown 格式应为 UID:GID: %q", opts.Chown)
+        }
+        opts.ChownUID, opts.ChownGID = u, g
+    }
+    if opts.Concurrency != "" && opts.Concurrency != "auto" {
+        if n, err := strconv.Atoi(opts.Concurrency); err != nil || n < 1 {
+            return fmt.Errorf("-concurrency 只能是正整数或 auto: %q", opts.Concurrency)
+        }
+    }
+    if opts.Channel != "lts" && opts.Channel != "current" {
+        return fmt.Errorf("-channel 只能是 lts 或 current: %q", opts.Channel)
+    }
+    if opts.AllowPrereleaseLTS && opts.LTSName == "" {
+        return fmt.Errorf("-allow-prerelease-lts 需要配合 -lts-name 使用")
+    }
+    if opts.Strict && opts.MaxAge <= 0 {
+        return fmt.Errorf("-strict 需要配合 -max-age 使用")
+    }
+    if opts.MaxAge > 0 && (opts.Version != "" || len(opts.Versions) > 0) {
+        return fmt.Errorf("-max-age 只检查从 index.json 选出的最新版本，不能与 -version/-versions 同时使用")
+    }
+    if opts.SinceDate != "" {
+        t, err := time.Parse(releaseDateLayout, opts.SinceDate)
+        if err != nil {
+            return fmt.Errorf("-since-date 格式应为 YYYY-MM-DD: %q", opts.SinceDate)
+        }
+        if opts.Version != "" || (opts.SourceDir != "" && len(opts.Versions) == 0) {
+            return fmt.Errorf("-since-date 不能与 -version 或单版本的 -source-dir 同时使用")
+        }
+        opts.Since = t
+    }
+    if len(opts.Versions) > 0 {
+        if opts.Version != "" || opts.Interval > 0 || opts.DumpURLs {
+            return fmt.Errorf("-versions 不能与 -version、-interval、-dump-urls 同时使用")
+        }
+    }
+    if opts.VersionConcurrency < 1 {
+        return fmt.Errorf("-version-concurrency 至少为 1: %d", opts.VersionConcurrency)
+    }
+    if opts.VersionConcurrency > 1 && len(opts.Versions) == 0 {
+        return fmt.Errorf("-version-concurrency 只能与 -versions 一起使用")
+    }
+    if versionsAll() {
+        // 不限条件时会选中 index.json 中的全部版本，要求至少给出一个收窄范围的条件
+        if opts.SinceDate == "" && opts.VersionRange == "" && opts.LTSName == "" {
+            return fmt.Errorf("-versions all 需要配合 -since-date、-version-range 或 -lts-name 使用")
+        }
+    } else if len(opts.Versions) > 0 {
+        seen := map[string]bool{}
+        for i, v := range opts.Versions {
+            nv, err := normalizeVersion(v)
+            if err != nil {
+                return err
+            }
+            if seen[nv] {
+                return fmt.Errorf("-versions 中重复的版本: %s", nv)
+            }
+            seen[nv] = true
+            opts.Versions[i] = nv
+        }
+    }
+    if opts.Version != "" {
+        v, err := normalizeVersion(opts.Version)
+        if err != nil {
+            return err
+        }
+        opts.Version = v
+    }
+    if opts.VersionRange != "" {
+        if _, err := parseRange(opts.VersionRange); err != nil {
+            return err
+        }
+    }
+    if opts.TargetsFile != "" {
+        if err := loadTargetsFile(opts.TargetsFile); err != nil {
+            return fmt.Errorf("读取 -targets-file 失败: %w", err)
+        }
+    }
+    if err := validateZstdLevelByArch(); err != nil {
+        return err
+    }
+    for _, pair := range opts.GoPlatforms {
+        platform, err := parseGoPair(pair)
+        if err != nil {
+            return err
+        }
+        opts.Platforms = append(opts.Platforms, platform)
+    }
+    if opts.PlatformsPreset != "" {
+        if opts.Only != "" || opts.Host {
+            return fmt.Errorf("-platforms-preset 不能与 -only/-host 同时使用")
+        }
+        platforms, err := expandPlatformPreset(opts.PlatformsPreset)
+        if err != nil {
+            return err
+        }
+        opts.Platforms = append(opts.Platforms, platforms...)
+    }
+    if opts.Host {
+        if opts.Only != "" || len(opts.Platforms) > 0 {
+            return fmt.Errorf("-host 不能与 -only/-platforms 同时使用")
+        }
+        platform, err := hostPlatform()
+        if err != nil {
+            return err
+        }
+        opts.Only = platform
+    }
+    if opts.NodeByBasename && opts.NodePathRegex != "" {
+        return fmt.Errorf("-node-by-basename 不能与 -node-path-regex 同时使用")
+    }
+    if opts.NodePathRegex != "" {
+        re, err := regexp.Compile(opts.NodePathRegex)
+        if err != nil {
+            return fmt.Errorf("-node-path-regex 无效: %w", err)
+        }
+        nodePathRe = re
+    }
+    if err := initArchiveTemplate(); err != nil {
+        return err
+    }
+    if len(opts.CrossCheckMirrors) > 0 {
+        if opts.SourceDir != "" || !opts.Checksum {
+            return fmt.Errorf("-cross-check-mirrors 需要从网络下载并开启校验")
+        }
+        if err := validateCrossCheckMirrors(); err != nil {
+            return err
+        }
+    }
+    if opts.SourceDir == "" {
+        return validateReleaseURL()
+    }
+    return nil
+}
+
+// 按 -concurrency 和目标数确定最终并发数，单独指定的 -concurrency-* 优先
+// auto: 解压和压缩按 CPU 数，下载取 min(4, 目标数)
+func applyConcurrency(numTargets int) {
+    if opts.Concurrency == "" {
+        return
+    }
+    download, compress := 0, 0
+    if opts.Concurrency == "auto" {
+        download = min(4, max(numTargets, 1))
+        compress = runtime.GOMAXPROCS(0)
+    } else {
+        n, _ := strconv.Atoi(opts.Concurrency)
+        download, compress = n, n
+    }
+
+    explicit := explicitFlags()
+    if !explicit["concurrency-downloads"] {
+        opts.DownloadConcurrency = download
+    }
+    if !explicit["extract-concurrency"] {
+        opts.ExtractConcurrency = compress
+    }
+    if !explicit["concurrency-compress"] {
+        opts.CompressConcurrency = compress
+    }
+    logf(levelPhase, "并发: 下载 %d，解压 %d，压缩 %d (-concurrency %s)\n",
+        opts.DownloadConcurrency, opts.ExtractConcurrency, opts.CompressConcurrency, opts.Concurrency)
+}
+
+// 命令行上显式给出的参数名
+func explicitFlags() map[string]bool {
+    explicit := map[string]bool{}
+    flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+    return explicit
+}
+
+// 按逗号拆分列表参数，忽略空项
+func splitList(v string) []string {
+    var out []string
+    for _, item := range strings.Split(v, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            out = append(out, item)
+        }
+    }
+    return out
+}
+
+// 解析带单位的大小，如 512MB、2G、1048576，单位按 1024 进位
+func parseSize(v string) (int64, error) {
+    s := strings.ToUpper(strings.TrimSpace(v))
+    s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+    mult := int64(1)
+    if s != "" {
+        if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+            mult = 1 << (10 * (i + 1))
+            s = s[:len(s)-1]
+        }
+    }
+    n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("无效的大小: %q", v)
+    }
+    return int64(n * float64(mult)), nil
+}
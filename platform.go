@@ -0,0 +1,87 @@
+package main
+
+import (
+    "fmt"
+    "runtime"
+    "sort"
+    "strings"
+)
+
+// Go 的 GOOS/GOARCH 与 Node 平台命名的对应关系
+var (
+    goosToNode = map[string]string{
+        "darwin":  "darwin",
+        "linux":   "linux",
+        "windows": "win",
+    }
+    goarchToNode = map[string]string{
+        "amd64": "x64",
+        "arm64": "arm64",
+        "arm":   "armv7l",
+        "386":   "x86",
+    }
+)
+
+// 把 GOOS/GOARCH 转成 targets 中的 Node 平台名
+func goPlatform(goos, goarch string) (string, error) {
+    osName, ok1 := goosToNode[goos]
+    arch, ok2 := goarchToNode[goarch]
+    platform := osName + "-" + arch
+    if !ok1 || !ok2 || !supportedPlatform(platform) {
+        return "", fmt.Errorf("%s/%s 没有对应的 Node 官方构建", goos, goarch)
+    }
+    return platform, nil
+}
+
+// 解析 -platforms-from-go 的一项，如 linux/amd64，大小写和两侧空白不敏感
+func parseGoPair(pair string) (string, error) {
+    goos, goarch, ok := strings.Cut(strings.ToLower(strings.TrimSpace(pair)), "/")
+    goos, goarch = strings.TrimSpace(goos), strings.TrimSpace(goarch)
+    if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+        return "", fmt.Errorf("-platforms-from-go 格式应为 GOOS/GOARCH: %q", pair)
+    }
+    return goPlatform(goos, goarch)
+}
+
+// 当前机器对应的平台
+func hostPlatform() (string, error) {
+    return goPlatform(runtime.GOOS, runtime.GOARCH)
+}
+
+func supportedPlatform(platform string) bool {
+    for _, p := range targets {
+        if p == platform {
+            return true
+        }
+    }
+    return false
+}
+
+// -platforms-preset 的常用分组；ci 为当前机器对应的平台，运行时解析
+var platformPresets = map[string][]string{
+    "desktop":   {"darwin-x64", "darwin-arm64", "win-x64", "win-arm64"},
+    "server":    {"linux-x64", "linux-arm64"},
+    "all-linux": {"linux-x64", "linux-arm64", "linux-armv7l"},
+    "ci":        nil,
+}
+
+// 展开 -platforms-preset，名称有误时列出可用的预设
+func expandPlatformPreset(name string) ([]string, error) {
+    platforms, ok := platformPresets[name]
+    if !ok {
+        names := make([]string, 0, len(platformPresets))
+        for n := range platformPresets {
+            names = append(names, n)
+        }
+        sort.Strings(names)
+        return nil, fmt.Errorf("未知的 -platforms-preset %q，可选: %s", name, strings.Join(names, ", "))
+    }
+    if name == "ci" {
+        platform, err := hostPlatform()
+        if err != nil {
+            return nil, err
+        }
+        return []string{platform}, nil
+    }
+    return platforms, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "reflect"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+// -print-config 中的一项设置
+type configEntry struct {
+    Key   string
+    Value any
+}
+
+// 可能带凭据的地址类参数，输出时去掉用户名密码和查询参数
+var credentialFields = map[string]bool{
+    "Mirror":            true,
+    "IndexURL":          true,
+    "CrossCheckMirrors": true,
+}
+
+// 密码类参数，设置了时只输出 REDACTED
+var secretFields = map[string]bool{
+    "SignPassphrase": true,
+}
+
+// 影响运行的环境变量
+var configEnv = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "NO_COLOR"}
+
+// -print-config: 输出参数校验、预设展开之后的全部生效设置然后退出，不访问网络
+// 格式沿用 -dump-format: text 为两列表格，json 为一个对象
+func printConfig() error {
+    entries := effectiveConfig()
+    if opts.DumpFormat == "json" {
+        var buf bytes.Buffer
+        buf.WriteString("{\n")
+        for i, e := range entries {
+            k, _ := json.Marshal(e.Key)
+            v, err := json.Marshal(e.Value)
+            if err != nil {
+                return fmt.Errorf("%s: %w", e.Key, err)
+            }
+            fmt.Fprintf(&buf, "  %s: %s", k, v)
+            if i < len(entries)-1 {
+                buf.WriteByte(',')
+            }
+            buf.WriteByte('\n')
+        }
+        buf.WriteString("}\n")
+        _, err := os.Stdout.Write(buf.Bytes())
+        return err
+    }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    for _, e := range entries {
+        fmt.Fprintf(tw, "%s\t%s\n", e.Key, formatConfigValue(e.Value))
+    }
+    return tw.Flush()
+}
+
+// 按 Options 的字段顺序列出所有设置，再附上实际使用的地址和环境变量
+func effectiveConfig() []configEntry {
+    var entries []configEntry
+    v := reflect.ValueOf(opts)
+    t := v.Type()
+    for i := 0; i < t.NumField(); i++ {
+        name := t.Field(i).Name
+        entries = append(entries, configEntry{name, configValue(name, v.Field(i).Interface())})
+    }
+    entries = append(entries, configEntry{"EffectiveIndexURL", redactURL(releaseSource.IndexURL())})
+    for _, name := range configEnv {
+        if val, ok := os.LookupEnv(name); ok {
+            entries = append(entries, configEntry{"env." + name, redactURL(val)})
+        }
+    }
+    return entries
+}
+
+// 时长和时间转成可读的字符串，地址类参数去掉凭据
+func configValue(name string, v any) any {
+    switch x := v.(type) {
+    case time.Duration:
+        return x.String()
+    case time.Time:
+        if x.IsZero() {
+            return ""
+        }
+        return x.Format(time.RFC3339)
+    case string:
+        if secretFields[name] && x != "" {
+            return "REDACTED"
+        }
+        if credentialFields[name] {
+            return redactURL(x)
+        }
+    case []string:
+        if credentialFields[name] {
+            out := make([]string, len(x))
+            for i, s := range x {
+                out[i] = redactURL(s)
+            }
+            return out
+        }
+    }
+    return v
+}
+
+// 去掉地址中的用户名密码和查询参数 (可能是签名或 token)，解析失败时原样返回
+func redactURL(s string) string {
+    u, err := url.Parse(s)
+    if err != nil || u.Host == "" {
+        return s
+    }
+    if u.RawQuery != "" {
+        u.RawQuery = "REDACTED"
+    }
+    if u.User != nil {
+        u.User = url.User("REDACTED")
+    }
+    return u.String()
+}
+
+func formatConfigValue(v any) string {
+    switch x := v.(type) {
+    case []string:
+        if len(x) == 0 {
+            return "-"
+        }
+        return strings.Join(x, ",")
+    case map[string]string:
+        if len(x) == 0 {
+            return "-"
+        }
+        b, _ := json.Marshal(x)
+        return string(b)
+    case string:
+        if x == "" {
+            return "-"
+        }
+        return x
+    }
+    return fmt.Sprint(v)
+}
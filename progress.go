@@ -0,0 +1,98 @@
+package main
+
+import (
+    "time"
+)
+
+// 进度所属的处理阶段，渲染时作为前缀
+type Phase int
+
+const (
+    PhaseDownload Phase = iota
+    PhaseExtract
+    PhaseCompress
+    PhaseVerify
+)
+
+func (p Phase) String() string {
+    switch p {
+    case PhaseDownload:
+        return "下载"
+    case PhaseExtract:
+        return "解压"
+    case PhaseCompress:
+        return "压缩"
+    case PhaseVerify:
+        return "校验"
+    }
+    return "处理"
+}
+
+// 进度刷新的最小间隔，也是 -speed-samples 的采样间隔
+const progressInterval = 300 * time.Millisecond
+
+// 进度条 Writer，Subject 为平台名或文件名
+type ProgressWriter struct {
+    Phase      Phase
+    Subject    string
+    Total      int64 // 未知时 <= 0，按已处理的 MB 显示
+    Written    int64
+    LastUpdate time.Time
+    Samples    *speedSamples // -speed-samples 时记录速度，nil 时不采样
+    Stall      *stallWatch   // -min-speed 时累加收到的字节数供看门狗检查，nil 时不计
+
+    sampleAt    time.Time
+    sampleBytes int64
+}
+
+func (pw *ProgressWriter) prefix() string {
+    return pw.Phase.String() + "[" + pw.Subject + "]"
+}
+
+func (pw *ProgressWriter) Write(p []byte) (int, error) {
+    n := len(p)
+    if pw.Samples != nil && pw.sampleAt.IsZero() {
+        // 从收到第一块数据开始计时，续传时已有的部分不计入
+        pw.sampleAt, pw.sampleBytes = time.Now(), pw.Written
+    }
+    pw.Written += int64(n)
+    pw.Stall.add(n)
+    if pw.Samples != nil {
+        pw.sample(progressInterval)
+    }
+    // 非终端输出时不刷新进度，避免日志中堆满 \r
+    if !useANSI {
+        return n, nil
+    }
+    now := time.Now()
+    if now.Sub(pw.LastUpdate) > progressInterval {
+        pw.LastUpdate = now
+        if pw.Total <= 0 {
+            logf(levelPhase, "\r%s %.1f MB", pw.prefix(), float64(pw.Written)/(1<<20))
+        } else {
+            percent := float64(pw.Written) / float64(pw.Total) * 100
+            logf(levelPhase, "\r%s %.1f%%", pw.prefix(), percent)
+        }
+    }
+    return n, nil
+}
+
+// 距上次采样超过 interval 时按这段时间的字节数记录一个速度样本
+func (pw *ProgressWriter) sample(interval time.Duration) {
+    now := time.Now()
+    elapsed := now.Sub(pw.sampleAt)
+    if elapsed < interval {
+        return
+    }
+    pw.Samples.add(float64(pw.Written-pw.sampleBytes) / elapsed.Seconds())
+    pw.sampleAt, pw.sampleBytes = now, pw.Written
+}
+
+// 阶段结束行
+func (pw *ProgressWriter) Done() {
+    // 最后不足一个间隔的部分，太短的尾巴不计，避免个别极端值
+    if pw.Samples != nil && !pw.sampleAt.IsZero() {
+        pw.sample(progressInterval / 2)
+    }
+    logf(levelPhase, "\r%s 100%%\n", pw.prefix())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// 工具自身版本，发布时通过 -ldflags "-X main.toolVersion=vX.Y.Z" 注入
+var toolVersion = "dev"
+
+const toolName = "update-sub-store-node"
+
+// 单个输出的来源证明
+type provenance struct {
+    Output       string    `json:"output"`
+    OutputSHA256 string    `json:"outputSha256"`
+    OriginalName string    `json:"originalName,omitempty"`
+    Platform     string    `json:"platform"`
+    NodeVersion  string    `json:"nodeVersion"`
+    SourceURL    string    `json:"sourceUrl"`
+    SourceSHA256 string    `json:"sourceSha256"`
+    Tool         string    `json:"tool"`
+    ToolVersion  string    `json:"toolVersion"`
+    BuiltAt      time.Time `json:"builtAt"`
+}
+
+func writeProvenance(res *targetResult) error {
+    p := provenance{
+        Output:       filepath.Base(res.OutFile),
+        OutputSHA256: res.OutputSHA256,
+        OriginalName: originalName(res.Platform),
+        Platform:     res.Platform,
+        NodeVersion:  res.Version,
+        SourceURL:    res.URL,
+        SourceSHA256: res.SourceSHA256,
+        Tool:         toolName,
+        ToolVersion:  toolVersion,
+        BuiltAt:      time.Now().UTC(),
+    }
+    return writeJSONFile(res.OutFile+".provenance.json", p)
+}
+
+// -sidecar-meta 写出的 <output>.meta，按文件索引产物的使用方不必读取汇总清单
+type sidecarMeta struct {
+    Archive      string `json:"archive"`
+    NodeVersion  string `json:"nodeVersion"`
+    Platform     string `json:"platform"`
+    SourceSHA256 string `json:"sourceSha256"`
+    OutputSHA256 string `json:"outputSha256"`
+    OriginalName string `json:"originalName,omitempty"` // 解压后应使用的文件名
+}
+
+func metaPath(output string) string {
+    return output + ".meta"
+}
+
+func writeSidecarMeta(res *targetResult) error {
+    m := sidecarMeta{
+        Archive:      archiveName(res.Version, res.Platform),
+        NodeVersion:  res.Version,
+        Platform:     res.Platform,
+        SourceSHA256: res.SourceSHA256,
+        OutputSHA256: res.OutputSHA256,
+        OriginalName: originalName(res.Platform),
+    }
+    return writeAtomic(metaPath(res.OutFile), func(part string) error {
+        return writeJSONFile(part, m)
+    })
+}
+
+func writeJSONFile(path string, v any) error {
+    data, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0o644)
+}
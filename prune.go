@@ -0,0 +1,165 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// -prune: 按语义化版本排序 -out 下的 <version>/ 子目录 (-versions 的输出布局)，只保留最新的 -keep 个
+// 只处理名称恰好是版本号的目录，其他文件和目录一律不动；-replace-existing-atomic 写出的 <version>
+// 是指向 <version>.releases/<时间戳> 的符号链接，删除时连同链接和整个 <version>.releases 一起删除
+// 待删除的目录中出现子目录或符号链接、或链接指向别处时，说明不是本工具写出的布局，整个操作在删除任何东西之前终止
+// -dry-run 时只列出将要删除的目录
+func runPrune() int {
+    entries, err := os.ReadDir(opts.Out)
+    if err != nil {
+        logf(levelError, "❌ 读取 -out 目录失败: %v\n", err)
+        return exitFailure
+    }
+
+    type versionDir struct {
+        name string
+        v    semver
+        link bool // -replace-existing-atomic 的符号链接
+    }
+    var dirs []versionDir
+    for _, e := range entries {
+        if !versionRe.MatchString(e.Name()) {
+            continue
+        }
+        link := e.Type()&os.ModeSymlink != 0
+        if !e.IsDir() && !link {
+            logf(levelSummary, "⏭️  %s 不是目录，跳过\n", e.Name())
+            continue
+        }
+        v, err := parseSemver(e.Name())
+        if err != nil {
+            continue
+        }
+        dirs = append(dirs, versionDir{e.Name(), v, link})
+    }
+    sort.Slice(dirs, func(i, j int) bool { return dirs[i].v.compare(dirs[j].v) > 0 })
+    if len(dirs) <= opts.Keep {
+        logf(levelSummary, "🧹 %s 中有 %d 个版本目录，不超过 -keep %d，无需清理\n", opts.Out, len(dirs), opts.Keep)
+        return exitOK
+    }
+
+    kept, pruned := dirs[:opts.Keep], dirs[opts.Keep:]
+    for _, d := range pruned {
+        dir := filepath.Join(opts.Out, d.name)
+        if d.link {
+            target, err := releaseTarget(dir)
+            if err != nil {
+                logf(levelError, "❌ %s: %v，未删除任何目录\n", d.name, err)
+                return exitFailure
+            }
+            dir = target
+        }
+        if err := checkPrunable(dir); err != nil {
+            logf(levelError, "❌ %s: %v，未删除任何目录\n", d.name, err)
+            return exitFailure
+        }
+    }
+    for _, d := range kept {
+        logf(levelPhase, "   保留 %s\n", d.name)
+    }
+
+    names := make([]string, len(pruned))
+    for i, d := range pruned {
+        names[i] = d.name
+    }
+    if opts.DryRun {
+        for _, name := range names {
+            logf(levelSummary, "🗑️  将删除 %s\n", filepath.Join(opts.Out, name))
+        }
+        logf(levelSummary, "\n🧹 -dry-run: 将删除 %d 个版本目录，保留 %d 个\n", len(names), len(kept))
+        return exitOK
+    }
+
+    var removed []string
+    for _, name := range names {
+        if err := removeVersionDir(filepath.Join(opts.Out, name)); err != nil {
+            logf(levelError, "❌ 删除 %s 失败: %v\n", name, err)
+            continue
+        }
+        removed = append(removed, name)
+        logf(levelSummary, "🗑️  已删除 %s\n", filepath.Join(opts.Out, name))
+    }
+    failed := len(names) - len(removed)
+    if err := pruneVersionsManifest(filepath.Join(opts.Out, "versions.json"), removed); err != nil {
+        logf(levelError, "❌ 更新 versions.json 失败: %v\n", err)
+        return exitFailure
+    }
+    logf(levelSummary, "\n🧹 清理完成: 删除 %d 个版本目录，保留 %d 个，失败 %d\n", len(removed), len(kept), failed)
+    return exitCode(len(names), failed)
+}
+
+// 符号链接 <version> 实际指向的目录，必须位于 <version>.releases 之中
+func releaseTarget(link string) (string, error) {
+    target, err := filepath.EvalSymlinks(link)
+    if err != nil {
+        return "", err
+    }
+    releases, err := filepath.EvalSymlinks(link + ".releases")
+    if err != nil {
+        return "", fmt.Errorf("是符号链接但没有对应的 %s.releases", filepath.Base(link))
+    }
+    if rel, err := filepath.Rel(releases, target); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+        return "", fmt.Errorf("符号链接指向 %s，不是 -replace-existing-atomic 的布局", target)
+    }
+    return target, nil
+}
+
+// 删除版本目录 (或符号链接) 以及 -replace-existing-atomic 留下的 <version>.releases
+func removeVersionDir(dir string) error {
+    if err := os.RemoveAll(longPath(dir)); err != nil {
+        return err
+    }
+    return os.RemoveAll(longPath(dir + ".releases"))
+}
+
+// 版本目录中只应有构建写出的普通文件 (输出、校验和、.meta 等)
+func checkPrunable(dir string) error {
+    info, err := os.Lstat(dir)
+    if err != nil {
+        return err
+    }
+    if !info.IsDir() {
+        return fmt.Errorf("不是目录")
+    }
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return err
+    }
+    for _, e := range entries {
+        if !e.Type().IsRegular() {
+            return fmt.Errorf("包含非普通文件 %s，不像是 -versions 的输出目录", e.Name())
+        }
+    }
+    return nil
+}
+
+// 从 versions.json 中移除已删除的版本，文件不存在时不做任何事
+func pruneVersionsManifest(path string, removed []string) error {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    manifest := map[string][]versionsEntry{}
+    if err := json.Unmarshal(data, &manifest); err != nil {
+        return err
+    }
+    for _, version := range removed {
+        delete(manifest, version)
+    }
+    return writeAtomic(path, func(part string) error {
+        return writeJSONFile(part, manifest)
+    })
+}
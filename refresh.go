@@ -0,0 +1,180 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// -refresh-metadata: 按 -out 中现有的输出重新生成校验和文件、.meta 和 versions.json，不下载也不重新构建
+// 用于手工替换或重新拷贝输出之后，让元数据与目录内容一致；-verify-only 只检查，这里负责重写
+// .provenance.json 是构建时的来源证明，不改写，哈希不符时只给出警告
+func runRefreshMetadata() int {
+    entries, err := os.ReadDir(opts.Out)
+    if err != nil {
+        logf(levelError, "❌ 读取 -out 目录失败: %v\n", err)
+        return exitFailure
+    }
+
+    sums := map[string]string{}
+    var total, failed int
+    for _, e := range entries {
+        // 其他 -prefix 的输出属于各自的校验和文件
+        if !e.Type().IsRegular() || !isCompressedOutput(e.Name()) || !strings.HasPrefix(e.Name(), opts.Prefix) {
+            continue
+        }
+        total++
+        output := filepath.Join(opts.Out, e.Name())
+        if err := refreshOutput(output, sums); err != nil {
+            failed++
+            logf(levelError, "❌ %s: %v\n", e.Name(), err)
+        }
+    }
+    if len(sums) > 0 {
+        name := outputShasumsName()
+        if err := writeShasums(filepath.Join(opts.Out, name), sums); err != nil {
+            logf(levelError, "❌ 写出 %s 失败: %v\n", name, err)
+            return exitFailure
+        }
+        logf(levelSummary, "📝 %s: %d 个文件\n", name, len(sums))
+    }
+
+    manifest := filepath.Join(opts.Out, "versions.json")
+    if _, err := os.Stat(manifest); err == nil {
+        n, err := refreshVersionsManifest(manifest)
+        total += n
+        if err != nil {
+            failed++
+            logf(levelError, "❌ 更新 versions.json 失败: %v\n", err)
+        }
+    }
+
+    if total == 0 {
+        logf(levelError, "❌ %s 中没有压缩输出\n", opts.Out)
+        return exitFailure
+    }
+    logf(levelSummary, "\n🔄 元数据刷新完成: 成功 %d，失败 %d\n", total-failed, failed)
+    return exitCode(total, failed)
+}
+
+// 重新计算单个输出的哈希，写入 sums 并更新已有的 .meta
+func refreshOutput(output string, sums map[string]string) error {
+    info, err := os.Stat(output)
+    if err != nil {
+        return err
+    }
+    sum, err := fileSHA256(output)
+    if err != nil {
+        return err
+    }
+    name := filepath.Base(output)
+    if opts.ChecksumAlgo == "sha256" {
+        sums[name] = sum
+    } else if sums[name], err = fileHash(output, outputChecksumAlgo().New); err != nil {
+        return err
+    }
+
+    if err := refreshSidecarMeta(output, sum); err != nil {
+        return fmt.Errorf("更新 .meta 失败: %w", err)
+    }
+    checkProvenance(output, sum)
+    logf(levelSummary, "✅ %s (%s, sha256 %s)\n", name, formatBytes(info.Size()), sum[:12])
+    return nil
+}
+
+// 只改写已存在的 .meta 中的输出哈希，其余字段 (版本、平台、源压缩包哈希) 无法从输出推断，保持不变
+func refreshSidecarMeta(output, sum string) error {
+    path := metaPath(output)
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    var m sidecarMeta
+    if err := json.Unmarshal(data, &m); err != nil {
+        return err
+    }
+    if m.OutputSHA256 == sum {
+        return nil
+    }
+    m.OutputSHA256 = sum
+    return writeAtomic(path, func(part string) error {
+        return writeJSONFile(part, m)
+    })
+}
+
+func checkProvenance(output, sum string) {
+    data, err := os.ReadFile(output + ".provenance.json")
+    if err != nil {
+        return
+    }
+    var p provenance
+    if json.Unmarshal(data, &p) == nil && p.OutputSHA256 != sum {
+        logf(levelError, "⚠️  %s.provenance.json 记录的输出哈希与当前文件不符，来源证明不会被改写，请重新构建\n",
+            filepath.Base(output))
+    }
+}
+
+// 按条目中的相对路径重新计算 versions.json 中每个输出的哈希，文件已不存在的条目删除
+// 返回处理的条目数
+func refreshVersionsManifest(path string) (int, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return 0, err
+    }
+    manifest := map[string][]versionsEntry{}
+    if err := json.Unmarshal(data, &manifest); err != nil {
+        return 0, err
+    }
+    versions := make([]string, 0, len(manifest))
+    for version := range manifest {
+        versions = append(versions, version)
+    }
+    sort.Strings(versions)
+
+    n := 0
+    for _, version := range versions {
+        kept := []versionsEntry{}
+        for _, e := range manifest[version] {
+            n++
+            output := filepath.Join(opts.Out, filepath.FromSlash(e.Output))
+            sum, err := fileSHA256(output)
+            if os.IsNotExist(err) {
+                logf(levelSummary, "🗑️  %s 已不存在，从 versions.json 中移除\n", e.Output)
+                continue
+            }
+            if err != nil {
+                return n, err
+            }
+            if sum != e.SHA256 {
+                logf(levelSummary, "✏️  %s sha256 %s -> %s\n", e.Output, shortSum(e.SHA256), sum[:12])
+                e.SHA256 = sum
+            }
+            if err := refreshSidecarMeta(output, sum); err != nil {
+                return n, err
+            }
+            checkProvenance(output, sum)
+            kept = append(kept, e)
+        }
+        manifest[version] = kept
+    }
+    if err := writeAtomic(path, func(part string) error {
+        return writeJSONFile(part, manifest)
+    }); err != nil {
+        return n, err
+    }
+    logf(levelSummary, "📝 versions.json: %d 个版本\n", len(versions))
+    return n, nil
+}
+
+func shortSum(sum string) string {
+    if len(sum) > 12 {
+        return sum[:12]
+    }
+    return sum
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "text/template"
+)
+
+// 发布来源: 版本索引、压缩包和 SHASUMS256.txt 的地址
+// 下载、校验等流程只通过 Source 拼地址，新的发布方式只需实现这个接口
+type Source interface {
+    IndexURL() string
+    ArchiveURL(version, platform string) string
+    ChecksumURL(version string) string
+    TarFormat() ArchiveFormat // 非 Windows 目标压缩包的格式，Windows 目标总是 zip
+}
+
+// 官方 dist 布局: <base>[/<release-path>]/<version>/<压缩包>
+// nodejs.org 以及 npmmirror、tuna 等完整镜像都是这种布局
+type distSource struct {
+    Base  string
+    Index string        // 为空时为 <base>/index.json
+    Tar   ArchiveFormat // 零值为 tar.xz
+}
+
+func (s *distSource) IndexURL() string {
+    if s.Index != "" {
+        return s.Index
+    }
+    return s.Base + "/index.json"
+}
+
+func (s *distSource) ArchiveURL(version, platform string) string {
+    return releaseDir(s.Base, version) + "/" + archiveName(version, platform)
+}
+
+func (s *distSource) ChecksumURL(version string) string {
+    return releaseDir(s.Base, version) + "/SHASUMS256.txt"
+}
+
+func (s *distSource) TarFormat() ArchiveFormat {
+    return s.Tar
+}
+
+const defaultGitHubTagTemplate = "{{.Version}}"
+
+// GitHub Releases: 每个版本一个 release，压缩包和 SHASUMS256.txt 作为 release 附件
+// 地址为 https://github.com/<owner>/<repo>/releases/download/<tag>/<附件名>，附件名沿用 -archive-template
+// GitHub 上没有 index.json，版本索引默认使用官方的
+type githubSource struct {
+    Repo  string // owner/repo
+    Tag   *template.Template
+    Index string
+}
+
+func (s *githubSource) IndexURL() string {
+    return s.Index
+}
+
+func (s *githubSource) ArchiveURL(version, platform string) string {
+    return s.releaseURL(version) + "/" + archiveName(version, platform)
+}
+
+func (s *githubSource) ChecksumURL(version string) string {
+    return s.releaseURL(version) + "/SHASUMS256.txt"
+}
+
+func (s *githubSource) TarFormat() ArchiveFormat {
+    return ArchiveTarXZ
+}
+
+func (s *githubSource) releaseURL(version string) string {
+    var buf bytes.Buffer
+    // 模板已在启动时验证过
+    s.Tag.Execute(&buf, struct{ Version string }{version})
+    return "https://github.com/" + s.Repo + "/releases/download/" + buf.String()
+}
+
+// unofficial-builds 项目: musl、旧 glibc 以及官方不提供的架构，目录布局与官方 dist 相同
+// 同一构建同时提供 tar.gz 和 tar.xz，这里使用 tar.gz
+const unofficialDist = "https://unofficial-builds.nodejs.org/download/release"
+
+// -source unofficial 时替换内置的 targets 表；并非每个版本都有全部平台，缺少的平台按 404 处理
+var unofficialTargets = map[string]string{
+    "node_linux_386.zst":            "linux-x86",
+    "node_linux_amd64_glibc217.zst": "linux-x64-glibc-217",
+    "node_linux_amd64_musl.zst":     "linux-x64-musl",
+    "node_linux_arm64_musl.zst":     "linux-arm64-musl",
+    "node_linux_armv6.zst":          "linux-armv6l",
+    "node_linux_loong64.zst":        "linux-loong64",
+    "node_linux_riscv64.zst":        "linux-riscv64",
+}
+
+// 当前使用的发布来源，由 initSource 按 -source 设置
+var releaseSource Source = &distSource{Base: defaultMirror}
+
+// 按 -source 选择发布来源，在 applyMirrorPreset 之后调用
+func initSource(explicit map[string]bool) error {
+    switch opts.Source {
+    case "nodejs":
+        releaseSource = &distSource{Base: mirrorBase(), Index: opts.IndexURL}
+    case "npmmirror":
+        if explicit["mirror"] || explicit["mirror-preset"] {
+            return fmt.Errorf("-source npmmirror 不能与 -mirror/-mirror-preset 同时使用")
+        }
+        p := mirrorPresets["taobao"]
+        opts.Mirror = p.Dist
+        index := p.Index
+        if opts.IndexURL != "" {
+            index = opts.IndexURL
+        }
+        releaseSource = &distSource{Base: p.Dist, Index: index}
+    case "unofficial":
+        if explicit["mirror"] || explicit["mirror-preset"] {
+            return fmt.Errorf("-source unofficial 不能与 -mirror/-mirror-preset 同时使用")
+        }
+        opts.Mirror = unofficialDist
+        index := opts.IndexURL
+        if index == "" {
+            index = unofficialDist + "/index.json"
+        }
+        releaseSource = &distSource{Base: unofficialDist, Index: index, Tar: ArchiveTarGZ}
+        // -targets-file 在之后读取，给出时仍以文件为准
+        targets = unofficialTargets
+    case "github":
+        if explicit["mirror"] || explicit["mirror-preset"] || opts.ReleasePath != "" {
+            return fmt.Errorf("-source github 不能与 -mirror/-mirror-preset/-release-path 同时使用")
+        }
+        owner, repo, ok := strings.Cut(opts.GitHubRepo, "/")
+        if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+            return fmt.Errorf("-source github 需要 -github-repo OWNER/REPO: %q", opts.GitHubRepo)
+        }
+        t, err := template.New("tag").Option("missingkey=error").Parse(opts.GitHubTagTemplate)
+        if err != nil {
+            return fmt.Errorf("-github-tag-template 解析失败: %w", err)
+        }
+        var buf bytes.Buffer
+        if err := t.Execute(&buf, struct{ Version string }{"v0.0.0"}); err != nil {
+            return fmt.Errorf("-github-tag-template 渲染失败: %w", err)
+        }
+        if buf.Len() == 0 || strings.Contains(buf.String(), "/") {
+            return fmt.Errorf("-github-tag-template 必须渲染为非空的 tag: %q", buf.String())
+        }
+        index := opts.IndexURL
+        if index == "" {
+            index = defaultMirror + "/index.json"
+        }
+        releaseSource = &githubSource{Repo: opts.GitHubRepo, Tag: t, Index: index}
+    default:
+        return fmt.Errorf("-source 只能是 nodejs、npmmirror、unofficial 或 github: %q", opts.Source)
+    }
+    if opts.Source != "github" && (opts.GitHubRepo != "" || explicit["github-tag-template"]) {
+        return fmt.Errorf("-github-repo/-github-tag-template 需要配合 -source github 使用")
+    }
+    return nil
+}
+
+// 目标使用的发布来源: -targets-file 给出 baseURL 时为该地址下的 dist 布局，压缩包格式与 -source 相同
+func targetSource(platform string) Source {
+    if o, ok := targetOverrides[platform]; ok && o.BaseURL != "" {
+        return &distSource{Base: strings.TrimRight(o.BaseURL, "/"), Tar: releaseSource.TarFormat()}
+    }
+    return releaseSource
+}
+
+// 目标压缩包的格式，决定扩展名和解压方式
+func archiveFormat(platform string) ArchiveFormat {
+    if strings.HasPrefix(platform, "win") {
+        return ArchiveZip
+    }
+    return targetSource(platform).TarFormat()
+}
+
+// 目标压缩包的下载地址
+func buildURL(version, platform string) string {
+    return targetSource(platform).ArchiveURL(version, platform)
+}
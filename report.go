@@ -0,0 +1,193 @@
+package main
+
+import (
+    "errors"
+    "html/template"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+// -report-html 中的一个版本: runPipeline 每跑完一个版本追加一项
+type reportRun struct {
+    Version string
+    Started time.Time
+    Elapsed time.Duration
+    Results []*targetResult
+}
+
+// 本次任务已完成的版本，-versions 时依次累积，常驻模式每轮重新开始
+var (
+    reportMu   sync.Mutex
+    reportRuns []reportRun
+)
+
+// 报告中的一行
+type reportRow struct {
+    Platform     string
+    Output       string
+    Status       string // ok、failed、skipped、deadline
+    Error        string
+    SourceBytes  int64
+    OutputBytes  int64
+    Ratio        float64
+    Duration     time.Duration
+    SourceSHA256 string
+    OutputSHA256 string
+}
+
+type reportSection struct {
+    Version string
+    Started time.Time
+    Elapsed time.Duration
+    Rows    []reportRow
+    OK      int
+    Failed  int
+}
+
+type reportData struct {
+    Generated   time.Time
+    Sections    []reportSection
+    SourceBytes int64
+    OutputBytes int64
+    Elapsed     time.Duration
+    OK          int
+    Failed      int
+}
+
+// 记录一个版本的结果并重写 -report-html，-versions 中途失败时已完成的版本也留在报告里
+// -version-concurrency 时多个版本可能同时完成，追加和重写报告都在锁内进行
+func recordReport(version string, started time.Time, results []*targetResult) error {
+    reportMu.Lock()
+    defer reportMu.Unlock()
+    reportRuns = append(reportRuns, reportRun{Version: version, Started: started, Elapsed: time.Since(started), Results: results})
+    return writeHTMLReport(opts.ReportHTML, reportRuns)
+}
+
+// 用 html/template 渲染自包含的单页报告 (内嵌 CSS，不引用外部资源)，便于贴到 wiki 或发给他人
+func writeHTMLReport(path string, runs []reportRun) error {
+    data := reportData{Generated: time.Now()}
+    for _, run := range runs {
+        sec := reportSection{Version: run.Version, Started: run.Started, Elapsed: run.Elapsed}
+        for _, res := range run.Results {
+            row := reportRow{
+                Platform:     res.Platform,
+                Output:       filepath.Base(res.OutFile),
+                Status:       "ok",
+                SourceBytes:  res.SourceBytes,
+                OutputBytes:  res.OutputBytes,
+                Duration:     res.Duration,
+                SourceSHA256: res.SourceSHA256,
+                OutputSHA256: res.OutputSHA256,
+            }
+            switch {
+            case errors.Is(res.Err, errDeadline):
+                row.Status = "deadline"
+            case res.Err != nil:
+                row.Status = "failed"
+                row.Error = res.Err.Error()
+            case res.Skipped:
+                row.Status = "skipped"
+            }
+            if res.Err == nil {
+                sec.OK++
+                if row.OutputSHA256 == "" {
+                    row.OutputSHA256, _ = fileSHA256(res.OutFile)
+                }
+            } else {
+                sec.Failed++
+            }
+            if row.SourceBytes > 0 {
+                row.Ratio = float64(row.OutputBytes) / float64(row.SourceBytes) * 100
+            }
+            data.SourceBytes += row.SourceBytes
+            data.OutputBytes += row.OutputBytes
+            sec.Rows = append(sec.Rows, row)
+        }
+        sort.Slice(sec.Rows, func(i, j int) bool { return sec.Rows[i].Platform < sec.Rows[j].Platform })
+        data.OK += sec.OK
+        data.Failed += sec.Failed
+        data.Elapsed += sec.Elapsed
+        data.Sections = append(data.Sections, sec)
+    }
+    return writeAtomic(path, func(part string) error {
+        f, err := os.Create(part)
+        if err != nil {
+            return err
+        }
+        if err := reportTemplate.Execute(f, data); err != nil {
+            f.Close()
+            return err
+        }
+        return f.Close()
+    })
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+    "bytes": formatBytes,
+    "dur":   func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+    "time":  func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+    "short": func(s string) string {
+        if len(s) > 16 {
+            return s[:16] + "…"
+        }
+        return s
+    },
+}).Parse(reportHTML))
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>Node 构建报告{{range .Sections}} {{.Version}}{{end}}</title>
+<style>
+body { font: 14px/1.5 -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 2em auto; max-width: 1100px; color: #222; padding: 0 1em; }
+h1 { font-size: 1.6em; margin-bottom: .2em; }
+h2 { font-size: 1.25em; margin-top: 2em; }
+.meta { color: #666; }
+.cards { display: flex; gap: 1em; flex-wrap: wrap; margin: 1em 0; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: .6em 1em; min-width: 9em; }
+.card b { display: block; font-size: 1.3em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #eee; padding: .4em .6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+td.num { text-align: right; white-space: nowrap; }
+code { font: 12px ui-monospace, Menlo, Consolas, monospace; }
+.ok { color: #1a7f37; }
+.failed { color: #cf222e; }
+.skipped, .deadline { color: #9a6700; }
+.err { color: #cf222e; font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>Node 构建报告</h1>
+<p class="meta">生成于 {{time .Generated}}</p>
+<div class="cards">
+<div class="card">成功<b class="ok">{{.OK}}</b></div>
+<div class="card">失败<b class="{{if .Failed}}failed{{end}}">{{.Failed}}</b></div>
+<div class="card">下载量<b>{{bytes .SourceBytes}}</b></div>
+<div class="card">输出<b>{{bytes .OutputBytes}}</b></div>
+<div class="card">耗时<b>{{dur .Elapsed}}</b></div>
+</div>
+{{range .Sections}}
+<h2>{{.Version}}</h2>
+<p class="meta">开始于 {{time .Started}}，耗时 {{dur .Elapsed}}，成功 {{.OK}}，失败 {{.Failed}}</p>
+<table>
+<tr><th>平台</th><th>状态</th><th>输出</th><th>源压缩包</th><th>输出大小</th><th>压缩比</th><th>耗时</th><th>SHA-256</th></tr>
+{{range .Rows}}<tr>
+<td>{{.Platform}}</td>
+<td class="{{.Status}}">{{.Status}}{{if .Error}}<div class="err">{{.Error}}</div>{{end}}</td>
+<td><code>{{.Output}}</code></td>
+<td class="num">{{if .SourceBytes}}{{bytes .SourceBytes}}{{end}}</td>
+<td class="num">{{if .OutputBytes}}{{bytes .OutputBytes}}{{end}}</td>
+<td class="num">{{if .Ratio}}{{printf "%.1f%%" .Ratio}}{{end}}</td>
+<td class="num">{{if .Duration}}{{dur .Duration}}{{end}}</td>
+<td>{{if .OutputSHA256}}<code title="{{.OutputSHA256}}">输出 {{short .OutputSHA256}}</code>{{end}}{{if .SourceSHA256}}<br><code title="{{.SourceSHA256}}">源 {{short .SourceSHA256}}</code>{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+
+    "golang.org/x/time/rate"
+)
+
+// 全局重试令牌桶，所有目标共享，限制整体重试速率
+var retryLimiter = rate.NewLimiter(rate.Inf, 1)
+
+func initRetryLimiter() {
+    if opts.RetryRate > 0 {
+        retryLimiter = rate.NewLimiter(rate.Limit(opts.RetryRate), 1)
+    }
+}
+
+// Retry-After 等待时长上限，避免服务器给出离谱的值时卡住整个任务
+const maxRetryAfter = 2 * time.Minute
+
+// 服务器返回非 200 状态码
+type httpStatusError struct {
+    StatusCode int
+    Status     string
+    RetryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+    return "HTTP " + e.Status
+}
+
+func newHTTPStatusError(resp *http.Response) *httpStatusError {
+    return &httpStatusError{
+        StatusCode: resp.StatusCode,
+        Status:     resp.Status,
+        RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+    }
+}
+
+// 解析 Retry-After，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(v string) time.Duration {
+    if v == "" {
+        return 0
+    }
+    if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+        return time.Duration(secs) * time.Second
+    }
+    if t, err := http.ParseTime(v); err == nil {
+        if d := time.Until(t); d > 0 {
+            return d
+        }
+    }
+    return 0
+}
+
+// 判断错误是否值得重试: 4xx 中只有 408/429 可重试，其余客户端错误重试无意义
+func retryable(err error) bool {
+    var se *httpStatusError
+    if errors.As(err, &se) {
+        return se.StatusCode >= 500 ||
+            se.StatusCode == http.StatusTooManyRequests ||
+            se.StatusCode == http.StatusRequestTimeout
+    }
+    return true
+}
+
+// 执行 fn，失败时在获取重试令牌后重试，最多 -retries 次 (-targets-file 可按目标覆盖)
+func withRetry(ctx context.Context, platform string, fn func() error) error {
+    err := fn()
+    retries := targetRetries(platform)
+    for attempt := 1; err != nil && attempt <= retries; attempt++ {
+        if !retryable(err) {
+            return err
+        }
+        var se *httpStatusError
+        if errors.As(err, &se) && se.StatusCode == http.StatusTooManyRequests && se.RetryAfter > 0 {
+            wait := min(se.RetryAfter, maxRetryAfter)
+            logf(levelPhase, "\n⏳ 限流[%s] 等待 %s 后重试\n", platform, wait)
+            select {
+            case <-time.After(wait):
+            case <-ctx.Done():
+                return err
+            }
+        }
+        if werr := retryLimiter.Wait(ctx); werr != nil {
+            return err
+        }
+        logf(levelPhase, "\n🔁 重试[%s] 第 %d 次: %v\n", platform, attempt, err)
+        err = fn()
+    }
+    return err
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// 单个目标的处理结果
+type targetResult struct {
+    OutFile      string
+    Platform     string
+    Version      string
+    URL          string
+    SourceSHA256 string // 仅在 needSourceHash 时计算
+    OutputSHA256 string
+    BinarySHA256 string // 解压后可执行文件的哈希，仅 -dedupe 时计算
+    SourceBytes  int64  // 源压缩包大小
+    OutputBytes  int64
+    SidecarBytes int64 // -also-gzip 的 .gz 大小
+    CompressTime time.Duration
+    Duration     time.Duration // 从开始处理到完成，含排队等待
+    Skipped      bool     // 按 -run-state 跳过的已完成目标
+    Uploads      []string // 设置 -s3-bucket 时待上传的输出和附属文件
+    Err          error
+}
+
+// 是否在下载时同步计算源压缩包的 SHA-256，由需要哈希的功能开启
+var needSourceHash bool
+
+// 下载受 I/O 限制、压缩受 CPU 限制，解压介于两者之间且要持有打开的压缩包，三个阶段分别限流
+var (
+    downloadSem chan struct{}
+    extractSem  chan struct{}
+    compressSem chan struct{}
+)
+
+// 按当前的并发设置创建各阶段的名额，-version-concurrency 时同时进行的所有版本共用同一组
+func initStageLimits() {
+    downloadSem = make(chan struct{}, opts.DownloadConcurrency)
+    downloadLimiter = nil
+    if opts.AdaptiveConcurrency {
+        downloadLimiter = newAdaptiveLimiter(opts.DownloadConcurrency)
+        logf(levelPhase, "📶 自适应下载并发: 从 1 开始，上限 %d\n", opts.DownloadConcurrency)
+    }
+    extractSem = make(chan struct{}, opts.ExtractConcurrency)
+    compressSem = make(chan struct{}, opts.CompressConcurrency)
+}
+
+// 占用一个并发名额，返回释放函数；sem 为 nil 时不限流，ctx 取消时放弃等待
+func acquire(ctx context.Context, sem chan struct{}) (func(), error) {
+    if sem == nil {
+        return func() {}, nil
+    }
+    select {
+    case sem <- struct{}{}:
+        return func() { <-sem }, nil
+    case <-ctx.Done():
+        return nil, context.Cause(ctx)
+    }
+}
+
+// -deadline 的截止时间，零值表示不限制
+var deadlineAt time.Time
+
+// 超过 -deadline 后不再开始新目标，已开始的目标照常完成
+var errDeadline = errors.New("已超过 -deadline，未开始")
+
+// 在目标开始实际工作前调用
+func checkDeadline() error {
+    if !deadlineAt.IsZero() && time.Now().After(deadlineAt) {
+        return errDeadline
+    }
+    return nil
+}
+
+// -max-failures 的计数，覆盖整个任务 (-versions 的所有版本)
+var failureCount atomic.Int64
+
+var errTooManyFailures = errors.New("失败数超过 -max-failures，已终止")
+
+// 404 (旧版本缺少该架构等) 和因 -deadline 未开始的目标不算真正的失败
+func genuineFailure(err error) bool {
+    var se *httpStatusError
+    if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+        return false
+    }
+    return !errors.Is(err, errDeadline)
+}
+
+// 累计真正的失败，超过 -max-failures 时取消其余目标；因取消而中止的目标不再计数
+func recordFailure(ctx context.Context, cancel context.CancelCauseFunc, res *targetResult) {
+    if opts.MaxFailures <= 0 || res.Err == nil || context.Cause(ctx) != nil || !genuineFailure(res.Err) {
+        return
+    }
+    if n := failureCount.Add(1); n == int64(opts.MaxFailures)+1 {
+        logf(levelError, "\n⛔ 真正的失败已达 %d 个，超过 -max-failures %d (最近: %s)，终止其余工作\n",
+            n, opts.MaxFailures, res.OutFile)
+        cancel(errTooManyFailures)
+    }
+}
+
+// 已超过 -max-failures 时不再开始新的版本
+func tooManyFailures() bool {
+    return opts.MaxFailures > 0 && failureCount.Load() > int64(opts.MaxFailures)
+}
+
+// 输出单个目标的结果行
+func logResult(res *targetResult, prefix string) {
+    switch {
+    case errors.Is(res.Err, errDeadline):
+        logf(levelSummary, "%s⏭️  %s 因 -deadline 跳过\n", prefix, res.OutFile)
+    case res.Err != nil:
+        logf(levelError, "%s❌ %s 失败: %v\n", prefix, res.OutFile, res.Err)
+    default:
+        logf(levelSummary, "%s✅ 完成: %s\n", prefix, res.OutFile)
+    }
+}
+
+// 处理所有目标并收集结果；-fail-fast 时首个失败会取消 ctx，其余目标随之中止
+func runTargets(ctx context.Context, cancel context.CancelCauseFunc, version, out string, selected map[string]string) []*targetResult {
+    // 单平台模式: 不启用并发，直接顺序执行
+    if opts.Only != "" {
+        var results []*targetResult
+        for outFile, platform := range selected {
+            outFile = outputName(out, outFile)
+            res := runTarget(ctx, version, outFile, platform)
+            logResult(res, "")
+            recordFailure(ctx, cancel, res)
+            results = append(results, res)
+        }
+        return results
+    }
+
+    var (
+        wg       sync.WaitGroup
+        mu       sync.Mutex
+        results  []*targetResult
+        failOnce sync.Once
+    )
+    for outFile, platform := range selected {
+        outFile = outputName(out, outFile)
+        wg.Add(1)
+        go func(outFile, platform string) {
+            defer wg.Done()
+
+            res := runTarget(ctx, version, outFile, platform)
+            logResult(res, "\n")
+            recordFailure(ctx, cancel, res)
+            if res.Err != nil && opts.FailFast && !errors.Is(res.Err, errDeadline) {
+                failOnce.Do(func() {
+                    logf(levelError, "\n⛔ %s 失败，-fail-fast 终止其余目标\n", outFile)
+                    cancel(fmt.Errorf("因 %s 失败被 -fail-fast 终止", outFile))
+                })
+            }
+
+            mu.Lock()
+            results = append(results, res)
+            mu.Unlock()
+        }(outFile, platform)
+    }
+    wg.Wait()
+    return results
+}
+
+func runTarget(ctx context.Context, version, outFile, platform string) *targetResult {
+    if err := context.Cause(ctx); err != nil {
+        return &targetResult{OutFile: outFile, Platform: platform, Err: err}
+    }
+    if err := checkDeadline(); err != nil {
+        return &targetResult{OutFile: outFile, Platform: platform, Err: err}
+    }
+    if out, ok := runStateFile.completed(version, platform); ok {
+        logf(levelPhase, "\n⏭️  %s 已在上次运行中完成，跳过\n", out)
+        res := &targetResult{OutFile: out, Platform: platform, Version: version, Skipped: true}
+        if info, err := os.Stat(out); err == nil {
+            res.OutputBytes = info.Size()
+        }
+        return res
+    }
+    started := time.Now()
+    res, err := processTarget(ctx, version, outFile, platform)
+    if err == nil {
+        err = runStateFile.markDone(version, platform, res.OutFile)
+    }
+    res.Duration = time.Since(started)
+    res.Err = err
+    return res
+}
+
+// 结束时核对请求的目标与结果: 每个请求的平台都应有一条结果，成功的结果输出文件应当存在
+// 返回没有结果或输出缺失的条目，用于防止目标在处理逻辑中被悄悄丢掉
+func missingTargets(selected map[string]string, results []*targetResult) []string {
+    want := map[string]int{}
+    for _, platform := range selected {
+        want[platform]++
+    }
+    var missing []string
+    for _, res := range results {
+        want[res.Platform]--
+        if res.Err != nil {
+            continue
+        }
+        if _, err := os.Stat(res.OutFile); err != nil {
+            missing = append(missing, fmt.Sprintf("%s (报告成功但 %s 不存在)", res.Platform, res.OutFile))
+        }
+    }
+    for platform, n := range want {
+        for ; n > 0; n-- {
+            missing = append(missing, platform+" (没有结果)")
+        }
+    }
+    sort.Strings(missing)
+    return missing
+}
+
+// 不同平台得到完全相同的可执行文件，通常说明平台映射或镜像有误
+func warnDuplicates(results []*targetResult) {
+    byHash := map[string][]string{}
+    for _, res := range results {
+        if res.Err == nil && res.BinarySHA256 != "" {
+            byHash[res.BinarySHA256] = append(byHash[res.BinarySHA256], res.Platform)
+        }
+    }
+    for sum, platforms := range byHash {
+        if len(platforms) < 2 {
+            continue
+        }
+        sort.Strings(platforms)
+        for i := 0; i < len(platforms); i++ {
+            for j := i + 1; j < len(platforms); j++ {
+                logf(levelError, "⚠️  %s 与 %s 的可执行文件完全相同 (sha256 %s)，请检查平台映射或镜像\n",
+                    platforms[i], platforms[j], sum[:12])
+            }
+        }
+    }
+}
+
+// 一行总计: 源压缩包总量、输出总量、整体压缩比和总耗时
+func printTotals(results []*targetResult, elapsed time.Duration) {
+    var src, out int64
+    var ok int
+    var compress time.Duration
+    var sidecar int64
+    for _, res := range results {
+        if res.Err != nil {
+            continue
+        }
+        ok++
+        src += res.SourceBytes
+        out += res.OutputBytes
+        compress += res.CompressTime
+        sidecar += res.SidecarBytes
+    }
+    ratio := 0.0
+    if src > 0 {
+        ratio = float64(out) / float64(src) * 100
+    }
+    logf(levelSummary, "📊 源压缩包 %s，输出 %s (%.1f%%)，%d 个平台，耗时 %s\n",
+        formatBytes(src), formatBytes(out), ratio, ok, elapsed.Round(time.Second))
+    if sidecar > 0 {
+        logf(levelSummary, "📊 旁路 .gz 输出 %s\n", formatBytes(sidecar))
+    }
+    if compress > 0 {
+        logf(levelSummary, "📊 压缩格式 %s，压缩耗时合计 %s\n", opts.Format, compress.Round(time.Millisecond))
+    }
+}
+
+func formatBytes(n int64) string {
+    const unit = 1024
+    if n < unit {
+        return fmt.Sprintf("%d B", n)
+    }
+    div, exp := int64(unit), 0
+    for m := n / unit; m >= unit; m /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
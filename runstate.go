@@ -0,0 +1,87 @@
+package main
+
+import (
+    "encoding/json"
+    "os"
+    "sync"
+)
+
+// 目标级断点: 记录已成功的 (版本, 平台)，重新运行时跳过
+type runState struct {
+    path string
+    mu   sync.Mutex
+
+    // "版本/平台" -> 输出文件路径
+    Completed map[string]string `json:"completed"`
+}
+
+// -run-state 未设置时为 nil
+var runStateFile *runState
+
+func loadRunState(path string) (*runState, error) {
+    s := &runState{path: path, Completed: map[string]string{}}
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, s); err != nil {
+        return nil, err
+    }
+    if s.Completed == nil {
+        s.Completed = map[string]string{}
+    }
+    return s, nil
+}
+
+func runStateKey(version, platform string) string {
+    return version + "/" + platform
+}
+
+// 已完成且输出文件仍然存在时返回输出路径
+func (s *runState) completed(version, platform string) (string, bool) {
+    if s == nil {
+        return "", false
+    }
+    s.mu.Lock()
+    out, ok := s.Completed[runStateKey(version, platform)]
+    s.mu.Unlock()
+    if !ok {
+        return "", false
+    }
+    if _, err := os.Stat(out); err != nil {
+        return "", false
+    }
+    return out, true
+}
+
+// 记录完成并立即落盘，进程崩溃也不会丢失已完成的进度
+func (s *runState) markDone(version, platform, outFile string) error {
+    if s == nil {
+        return nil
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.Completed[runStateKey(version, platform)] = outFile
+    data, err := json.MarshalIndent(s, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeAtomic(s.path, func(part string) error {
+        return os.WriteFile(part, data, 0o644)
+    })
+}
+
+// 全部完成后删除状态文件
+func (s *runState) finish() error {
+    if s == nil {
+        return nil
+    }
+    err := os.Remove(s.path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    return err
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "mime"
+    "net/http"
+    "net/url"
+    "os"
+    "path"
+    "path/filepath"
+    "time"
+
+    "github.com/aws/aws-sdk-go-v2/aws"
+    awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+    "github.com/aws/aws-sdk-go-v2/config"
+    "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// 输出上传到 S3 兼容存储，未设置 -s3-bucket 时为 nil
+var s3Store *s3Uploader
+
+type s3Uploader struct {
+    client *s3.Client
+    bucket string
+    prefix string
+}
+
+// 凭据按 AWS SDK 默认链读取: AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY、共享配置文件、实例角色等
+// -s3-endpoint 用于 MinIO 等兼容存储
+func newS3Uploader(ctx context.Context) (*s3Uploader, error) {
+    // 沿用共享客户端的代理、拨号和超时设置；用 SDK 自带的可构建客户端以保留 AWS_CA_BUNDLE 支持
+    client := awshttp.NewBuildableClient()
+    if tr, ok := sharedTransport(); ok {
+        client = client.WithTransportOptions(func(t *http.Transport) {
+            t.Proxy = tr.Proxy
+            t.DialContext = tr.DialContext
+            t.TLSHandshakeTimeout = tr.TLSHandshakeTimeout
+            t.ResponseHeaderTimeout = tr.ResponseHeaderTimeout
+        })
+    }
+    loadOpts := []func(*config.LoadOptions) error{config.WithHTTPClient(client)}
+    if opts.S3Region != "" {
+        loadOpts = append(loadOpts, config.WithRegion(opts.S3Region))
+    }
+    cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+    if err != nil {
+        return nil, fmt.Errorf("加载 S3 配置失败: %w", err)
+    }
+    if cfg.Region == "" {
+        // MinIO 等自建存储通常不关心区域，但签名需要一个值
+        cfg.Region = "us-east-1"
+    }
+    s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+        if opts.S3Endpoint != "" {
+            o.BaseEndpoint = aws.String(opts.S3Endpoint)
+        }
+        o.UsePathStyle = opts.S3PathStyle
+    })
+    return &s3Uploader{client: s3Client, bucket: opts.S3Bucket, prefix: opts.S3Prefix}, nil
+}
+
+// 上传对象的 Content-Type: 压缩输出按格式，附属 JSON 为 application/json
+func contentType(file string) string {
+    if ext := filepath.Ext(file); ext == ".json" || ext == ".meta" {
+        return "application/json"
+    }
+    if c, ok := compressorByExt(file); ok {
+        return c.ContentType
+    }
+    return "application/octet-stream"
+}
+
+// 对象的自定义元数据: 构建信息加上 -s3-meta
+func objectMetadata(res *targetResult) map[string]string {
+    meta := map[string]string{
+        "build-time": time.Now().UTC().Format(time.RFC3339),
+    }
+    // 校验和文件和 versions.json 不属于单个目标，没有的字段不写
+    for k, v := range map[string]string{
+        "node-version":  res.Version,
+        "platform":      res.Platform,
+        "source-sha256": res.SourceSHA256,
+    } {
+        if v != "" {
+            meta[k] = v
+        }
+    }
+    for k, v := range opts.S3Meta {
+        meta[k] = v
+    }
+    return meta
+}
+
+// 先上传到临时键，成功后复制到最终键再删除临时键，避免读者看到半截对象
+// 复制时沿用临时对象的 Content-Type、Content-Disposition 和元数据
+// name 是文件相对 -out 的路径 (-versions 时带 <version>/)，对象键为 <s3-prefix>/<name>
+func (u *s3Uploader) upload(ctx context.Context, file, name string, res *targetResult) error {
+    key := path.Join(u.prefix, name)
+    tmpKey := key + ".part-" + randomSuffix()
+
+    f, err := os.Open(file)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    info, err := f.Stat()
+    if err != nil {
+        return err
+    }
+
+    _, err = u.client.PutObject(ctx, &s3.PutObjectInput{
+        Bucket:        aws.String(u.bucket),
+        Key:           aws.String(tmpKey),
+        Body:          f,
+        ContentLength: aws.Int64(info.Size()),
+
+        ContentType:        aws.String(contentType(file)),
+        ContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(file)})),
+        Metadata:           objectMetadata(res),
+    })
+    if err != nil {
+        return fmt.Errorf("上传 s3://%s/%s 失败: %w", u.bucket, tmpKey, err)
+    }
+    // 无论复制是否成功都清理临时键
+    defer u.client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+        Bucket: aws.String(u.bucket),
+        Key:    aws.String(tmpKey),
+    })
+
+    _, err = u.client.CopyObject(ctx, &s3.CopyObjectInput{
+        Bucket:     aws.String(u.bucket),
+        Key:        aws.String(key),
+        CopySource: aws.String((&url.URL{Path: u.bucket + "/" + tmpKey}).EscapedPath()),
+    })
+    if err != nil {
+        return fmt.Errorf("复制到 s3://%s/%s 失败: %w", u.bucket, key, err)
+    }
+    logf(levelPhase, "☁️  已上传 s3://%s/%s\n", u.bucket, key)
+    return nil
+}
+
+// 版本目录发布后上传各目标记下的文件，最后上传校验和文件 (及其签名)
+// 原子输出时 dir 已是正式目录；上传失败的目标记为失败，返回新增的失败数
+func uploadVersion(ctx context.Context, dir string, results []*targetResult) int {
+    sub, err := filepath.Rel(opts.Out, dir)
+    if err != nil {
+        sub = filepath.Base(dir)
+    }
+    keyOf := func(file string) string {
+        return path.Join(filepath.ToSlash(sub), filepath.Base(file))
+    }
+    failed := 0
+    version := ""
+    for _, res := range results {
+        if res.Err != nil {
+            continue
+        }
+        version = res.Version
+        for _, file := range res.Uploads {
+            if err := s3Store.upload(ctx, file, keyOf(file), res); err != nil {
+                logf(levelError, "❌ [%s] %v\n", res.Platform, err)
+                res.Err = err
+                failed++
+                break
+            }
+        }
+    }
+    if version == "" {
+        return failed
+    }
+    shasums := filepath.Join(dir, outputShasumsName())
+    for _, file := range []string{shasums, shasums + ".asc"} {
+        if _, err := os.Stat(file); err != nil {
+            continue
+        }
+        if err := s3Store.upload(ctx, file, keyOf(file), &targetResult{Version: version}); err != nil {
+            logf(levelError, "❌ %v\n", err)
+            return max(failed, 1)
+        }
+    }
+    return failed
+}
+
+func randomSuffix() string {
+    b := make([]byte, 6)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "sync"
+)
+
+// 服务器不支持 Range 或未给出大小，改用单连接下载
+var errNoRanges = errors.New("服务器不支持分段下载")
+
+// 文件太小，不值得拆分，直接用单连接下载
+var errSegmentsTooSmall = errors.New("文件太小，不分段")
+
+// 每段至少这么大，小文件不值得拆分
+const minSegmentSize = 1 << 20
+
+// -segments: 先 HEAD 确认支持 Range 并取得大小，再用 N 个连接并发下载互不重叠的区间，按偏移 WriteAt 到同一文件
+// 各段请求带 If-Range，文件在下载期间变化时服务器返回 200，整次下载失败后按重试规则从头再来
+// 源压缩包的 SHA-256 在全部段完成后对文件计算
+func downloadSegmented(ctx context.Context, filename, url, platform string) (string, error) {
+    size, validator, err := probeRanges(ctx, url)
+    if err != nil {
+        return "", err
+    }
+    n := int64(opts.Segments)
+    if size/n < minSegmentSize {
+        n = max(size/minSegmentSize, 1)
+    }
+    if n < 2 {
+        return "", errSegmentsTooSmall
+    }
+
+    out, err := os.Create(filename)
+    if err != nil {
+        return "", err
+    }
+    defer out.Close()
+    if err := out.Truncate(size); err != nil {
+        return "", err
+    }
+    logf(levelPhase, "\n🧩 分段[%s] %d 个连接下载 %s\n", platform, n, formatBytes(size))
+
+    ctx, cancel := context.WithCancelCause(ctx)
+    defer cancel(nil)
+    pw := &lockedWriter{w: &ProgressWriter{Phase: PhaseDownload, Subject: platform, Total: size, Samples: speedRecorder(url), Stall: stallCounter(ctx)}}
+    var wg sync.WaitGroup
+    chunk := size / n
+    for i := int64(0); i < n; i++ {
+        start, end := i*chunk, (i+1)*chunk-1
+        if i == n-1 {
+            end = size - 1
+        }
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if err := fetchSegment(ctx, out, url, validator, start, end, pw); err != nil {
+                cancel(fmt.Errorf("分段 %d-%d: %w", start, end, err))
+            }
+        }()
+    }
+    wg.Wait()
+    if err := context.Cause(ctx); err != nil {
+        return "", err
+    }
+    pw.w.Done()
+    if err := out.Close(); err != nil {
+        return "", err
+    }
+    if !needSourceHash {
+        return "", nil
+    }
+    return fileSHA256(filename)
+}
+
+// HEAD 确认服务器支持字节区间，返回大小和用于 If-Range 的校验值
+func probeRanges(ctx context.Context, url string) (int64, string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+    if err != nil {
+        return 0, "", err
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return 0, "", err
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return 0, "", newHTTPStatusError(resp)
+    }
+    if resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 {
+        return 0, "", errNoRanges
+    }
+    rs := &resumeState{}
+    rs.remember(resp)
+    return resp.ContentLength, rs.validator, nil
+}
+
+// 下载 [start, end] 写入 out 的对应位置
+func fetchSegment(ctx context.Context, out *os.File, url, validator string, start, end int64, progress io.Writer) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+    if validator != "" {
+        req.Header.Set("If-Range", validator)
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    switch resp.StatusCode {
+    case http.StatusPartialContent:
+    case http.StatusOK:
+        return errors.New("服务器返回完整文件，文件可能在下载期间发生了变化")
+    default:
+        return newHTTPStatusError(resp)
+    }
+    if got, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || got != start {
+        return fmt.Errorf("Content-Range 与请求不一致: %q", resp.Header.Get("Content-Range"))
+    }
+    want := end - start + 1
+    w := io.NewOffsetWriter(out, start)
+    n, err := io.Copy(io.MultiWriter(w, progress), io.LimitReader(resp.Body, want))
+    if err != nil {
+        return err
+    }
+    if n != want {
+        return fmt.Errorf("只收到 %d/%d 字节: %w", n, want, io.ErrUnexpectedEOF)
+    }
+    return nil
+}
+
+// 多个分段共用一个进度条
+type lockedWriter struct {
+    mu sync.Mutex
+    w  *ProgressWriter
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.w.Write(p)
+}
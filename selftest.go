@@ -0,0 +1,238 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "text/template"
+)
+
+// 自检夹具使用的版本号，不会与真实发布混淆
+const selfTestVersion = "v0.0.0"
+
+// -selftest: 用内存中的夹具完整跑一遍流水线 (下载、SHA-256 校验、解压、压缩)，
+// 再逐个解压输出与夹具中的可执行文件比对，并检查输出校验和的写出、解析和校验
+// 夹具由本机回环地址上的临时服务提供，不访问网络；用于验证新构建或交叉编译的二进制和运行环境
+// 压缩相关参数 (-format、-zstd-level 等) 照常生效，来源、版本、目标选择和写到 -out 以外的功能一律不生效
+func runSelfTest() int {
+    dir, err := os.MkdirTemp(opts.TmpDir, "update-node-selftest-")
+    if err != nil {
+        logf(levelError, "❌ 创建临时目录失败: %v\n", err)
+        return exitFailure
+    }
+
+    platforms := make([]string, 0, len(targets))
+    for _, platform := range targets {
+        platforms = append(platforms, platform)
+    }
+    sort.Strings(platforms)
+    fx, err := newFixture(selfTestVersion, platforms)
+    if err != nil {
+        logf(levelError, "❌ 生成夹具失败: %v\n", err)
+        return exitFailure
+    }
+    srv := fx.Serve()
+    defer srv.Close()
+    selfTestOptions(filepath.Join(dir, "out"), srv.URL)
+
+    checks := []struct {
+        name string
+        run  func() error
+    }{
+        {"构建全部目标", func() error { return selfTestBuild(opts.Out) }},
+        {"解压输出与夹具一致", func() error { return selfTestRoundTrip(opts.Out, platforms) }},
+        {"输出校验和 (" + opts.ChecksumAlgo + ")", func() error { return selfTestChecksums(opts.Out) }},
+        {"拒绝被篡改的源压缩包", func() error { return selfTestTampered(fx, filepath.Join(dir, "tampered"), platforms[0]) }},
+    }
+    failed := 0
+    for _, c := range checks {
+        logf(levelSummary, "\n🧪 %s\n", c.name)
+        if err := c.run(); err != nil {
+            failed++
+            logf(levelError, "❌ %s: %v\n", c.name, err)
+        } else {
+            logf(levelSummary, "✅ %s\n", c.name)
+        }
+    }
+
+    if failed > 0 {
+        // 失败时保留临时目录便于排查
+        logf(levelError, "\n🧪 自检失败: %d/%d 项未通过，临时文件保留在 %s\n", failed, len(checks), dir)
+        return exitFailure
+    }
+    os.RemoveAll(dir)
+    logf(levelSummary, "\n🧪 自检通过: %d 项，%d 个平台，格式 %s\n", len(checks), len(platforms), opts.Format)
+    return exitOK
+}
+
+// 把来源指向夹具、输出指向临时目录，并关闭依赖真实发布或会写到临时目录以外的功能
+func selfTestOptions(out, base string) {
+    opts.Version = selfTestVersion
+    opts.Versions = nil
+    opts.SourceDir = ""
+    opts.MaxAge = 0
+    opts.ChecksumMode = checksumRequired
+    opts.Checksum = true
+    opts.VerifyGPG = false
+    opts.CrossCheckMirrors = nil
+    opts.ReleasePath = ""
+    opts.Extra = nil
+    opts.ExtractDir = ""
+    opts.RawBinary = false
+    opts.VerifyVersion = false
+    opts.Platforms = nil
+    opts.Only = ""
+    opts.Prefix = ""
+    opts.Out = out
+    opts.ReplaceAtomic = false
+    opts.RunState = ""
+    opts.EmitSRI = ""
+    opts.ReportHTML = ""
+    opts.S3Bucket = ""
+    opts.Chown = ""
+    opts.ChownUID, opts.ChownGID = -1, -1
+    opts.MinFreeSpace = 0
+    opts.Deadline = 0
+    opts.MaxFailures = 0
+    opts.FailFast = false
+
+    archiveTmpl = template.Must(template.New("archive").Parse(defaultArchiveTemplate))
+    nodePathRe = nil
+    targetOverrides = map[string]targetEntry{}
+    releaseSource = &distSource{Base: base}
+    // 夹具只在回环地址上提供，不经过代理
+    httpClient = &http.Client{Transport: &http.Transport{}}
+    initRetryLimiter()
+}
+
+func selfTestBuild(out string) error {
+    if err := os.MkdirAll(out, 0o755); err != nil {
+        return err
+    }
+    _, code, err := runPipeline(context.Background())
+    if err != nil {
+        return err
+    }
+    if code != exitOK {
+        return fmt.Errorf("流水线退出码 %d", code)
+    }
+    return nil
+}
+
+// 用与输出相同的格式解压每个输出，内容应与夹具中的桩可执行文件逐字节相同
+func selfTestRoundTrip(out string, platforms []string) error {
+    byPlatform := map[string]string{}
+    for outFile, platform := range targets {
+        byPlatform[platform] = outputName(out, outFile)
+    }
+    c := compressors[opts.Format]
+    for _, platform := range platforms {
+        f, err := os.Open(byPlatform[platform])
+        if err != nil {
+            return err
+        }
+        dec, err := c.NewReader(f)
+        if err != nil {
+            f.Close()
+            return fmt.Errorf("%s: %w", platform, err)
+        }
+        got, err := io.ReadAll(dec)
+        f.Close()
+        if err != nil {
+            return fmt.Errorf("%s: 解压失败: %w", platform, err)
+        }
+        if !bytes.Equal(got, stubBinary(platform)) {
+            return fmt.Errorf("%s: 解压结果与夹具不一致 (%d 字节)", platform, len(got))
+        }
+        logf(levelPhase, "   %s ✅ %s\n", platform, filepath.Base(byPlatform[platform]))
+    }
+    return nil
+}
+
+// 读回构建按 -checksum-algo 写出的输出校验和文件，应列出每个输出并逐个校验通过；再确认错误的校验和会被发现
+func selfTestChecksums(out string) error {
+    algo := outputChecksumAlgo()
+    entries, err := os.ReadDir(out)
+    if err != nil {
+        return err
+    }
+    sums := map[string]string{}
+    for _, e := range entries {
+        if !isCompressedOutput(e.Name()) {
+            continue
+        }
+        if sums[e.Name()], err = fileHash(filepath.Join(out, e.Name()), algo.New); err != nil {
+            return err
+        }
+    }
+    if len(sums) == 0 {
+        return fmt.Errorf("%s 中没有压缩输出", out)
+    }
+    file := outputShasumsName()
+    data, err := os.ReadFile(filepath.Join(out, file))
+    if err != nil {
+        return err
+    }
+    parsed, err := parseShasums(bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    var names []string
+    for name, sum := range sums {
+        if parsed[name] != sum {
+            return fmt.Errorf("%s 中 %s 的校验和与输出不一致", file, name)
+        }
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        if err := verifyOutput(filepath.Join(out, name), parsed[name], algo.New); err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+    }
+    wrong := strings.Repeat("0", len(sums[names[0]]))
+    if verifyOutput(filepath.Join(out, names[0]), wrong, algo.New) == nil {
+        return fmt.Errorf("错误的校验和未被发现")
+    }
+    return nil
+}
+
+// 改动夹具中一个压缩包的内容但保留原来的 SHASUMS256.txt，构建该平台必须失败
+func selfTestTampered(fx *fixture, out, platform string) error {
+    name := path.Join(selfTestVersion, archiveName(selfTestVersion, platform))
+    orig := fx.Files[name]
+    tampered := bytes.Clone(orig)
+    tampered[len(tampered)/2] ^= 0xff
+    fx.Files[name] = tampered
+    defer func() { fx.Files[name] = orig }()
+
+    if err := os.MkdirAll(out, 0o755); err != nil {
+        return err
+    }
+    selected := map[string]string{}
+    for outFile, p := range targets {
+        if p == platform {
+            selected[outFile] = p
+        }
+    }
+    logf(levelSummary, "   以下 %s 的失败是预期的\n", platform)
+    results, _, err := buildVersion(context.Background(), selfTestVersion, out, selected)
+    if err != nil {
+        return err
+    }
+    for _, res := range results {
+        var ce *ChecksumError
+        if !errors.As(res.Err, &ce) {
+            return fmt.Errorf("%s 的压缩包被篡改后没有因校验和不符而失败 (结果: %v)", platform, res.Err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sync"
+)
+
+var (
+    localSumsOnce sync.Once
+    localSums     map[string]string
+    localSumsErr  error
+)
+
+// 读取 -source-dir 中的 SHASUMS256.txt，不存在时返回 nil
+// -verify-gpg 时改读签名版 SHASUMS256.txt.asc，不存在即失败
+func loadLocalShasums() (map[string]string, error) {
+    localSumsOnce.Do(func() {
+        if opts.VerifyGPG {
+            path := filepath.Join(opts.SourceDir, "SHASUMS256.txt.asc")
+            data, err := os.ReadFile(path)
+            if err != nil {
+                localSumsErr = fmt.Errorf("-verify-gpg 需要签名文件: %w", err)
+                return
+            }
+            signed, err := verifyClearsigned(data, path)
+            if err != nil {
+                localSumsErr = err
+                return
+            }
+            localSums, localSumsErr = parseShasums(bytes.NewReader(signed))
+            return
+        }
+        f, err := os.Open(filepath.Join(opts.SourceDir, "SHASUMS256.txt"))
+        if os.IsNotExist(err) {
+            return
+        }
+        if err != nil {
+            localSumsErr = err
+            return
+        }
+        defer f.Close()
+        localSums, localSumsErr = parseShasums(f)
+    })
+    return localSums, localSumsErr
+}
+
+// 在 -source-dir 中查找预先下载的压缩包，存在 SHASUMS256.txt 时按 -checksum-mode 校验
+// 需要哈希时同时返回压缩包的 SHA-256
+func localArchive(version, platform string) (path, sum string, err error) {
+    name := archiveName(version, platform)
+    path = filepath.Join(opts.SourceDir, name)
+    if _, err := os.Stat(path); err != nil {
+        return "", "", fmt.Errorf("本地压缩包不存在: %w", err)
+    }
+
+    var sums map[string]string
+    if opts.Checksum {
+        if sums, err = loadLocalShasums(); err != nil {
+            return "", "", fmt.Errorf("读取 SHASUMS256.txt 失败: %w", err)
+        }
+        if sums == nil {
+            if opts.ChecksumMode == checksumRequired {
+                return "", "", fmt.Errorf("%s 中没有 SHASUMS256.txt (-checksum-mode required)", opts.SourceDir)
+            }
+            logf(levelSummary, "⚠️  校验[%s] %s 中没有 SHASUMS256.txt，跳过校验\n", platform, opts.SourceDir)
+        }
+    }
+    if sums == nil && !needSourceHash {
+        return path, "", nil
+    }
+
+    sum, err = fileSHA256(path)
+    if err != nil {
+        return "", "", err
+    }
+    if sums != nil {
+        if err := verifyChecksum(sums, name, sum, platform); err != nil {
+            return "", "", &ChecksumError{Platform: platform, URL: path, Err: err}
+        }
+    }
+    return path, sum, nil
+}
+
+var localArchiveRe = regexp.MustCompile(`^node-(v\d+\.\d+\.\d+)-[^/]+\.(tar\.xz|tar\.gz|zip)$`)
+
+// 未指定 -version 时从 -source-dir 的文件名推断版本，要求目录内只有一个版本
+func detectLocalVersion() (string, error) {
+    entries, err := os.ReadDir(opts.SourceDir)
+    if err != nil {
+        return "", err
+    }
+    version := ""
+    for _, e := range entries {
+        m := localArchiveRe.FindStringSubmatch(e.Name())
+        if m == nil {
+            continue
+        }
+        if version != "" && version != m[1] {
+            return "", fmt.Errorf("%s 中包含多个版本 (%s, %s)，请用 -version 指定", opts.SourceDir, version, m[1])
+        }
+        version = m[1]
+    }
+    if version == "" {
+        return "", fmt.Errorf("%s 中没有找到 node 压缩包", opts.SourceDir)
+    }
+    return version, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+    "math"
+    "sort"
+    "sync"
+)
+
+// -speed-samples: 下载过程中每个进度刷新间隔记录一次速度，结束时按目标给出分布，
+// 用于发现最终平均速度掩盖的中途降速 (镜像抖动、限速)
+type speedSamples struct {
+    mu     sync.Mutex
+    values []float64 // 字节/秒
+}
+
+func (s *speedSamples) add(v float64) {
+    s.mu.Lock()
+    s.values = append(s.values, v)
+    s.mu.Unlock()
+}
+
+// 按下载地址记录，续传和重试的样本都记在同一地址下；-versions 时不同版本的地址不同，互不干扰
+var (
+    speedMu    sync.Mutex
+    speedByURL = map[string]*speedSamples{}
+)
+
+// 返回 url 的采样记录，未开启 -speed-samples 时返回 nil，ProgressWriter 据此不采样
+func speedRecorder(url string) *speedSamples {
+    if !opts.SpeedSamples {
+        return nil
+    }
+    speedMu.Lock()
+    defer speedMu.Unlock()
+    s, ok := speedByURL[url]
+    if !ok {
+        s = &speedSamples{}
+        speedByURL[url] = s
+    }
+    return s
+}
+
+// 取出并删除 url 的样本
+func takeSpeedSamples(url string) []float64 {
+    speedMu.Lock()
+    s := speedByURL[url]
+    delete(speedByURL, url)
+    speedMu.Unlock()
+    if s == nil {
+        return nil
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.values
+}
+
+// 一个目标的速度分布，写入 -speed-samples-json
+type speedStats struct {
+    Version  string    `json:"version"`
+    Platform string    `json:"platform"`
+    URL      string    `json:"url"`
+    Min      float64   `json:"min"`
+    Median   float64   `json:"median"`
+    P95      float64   `json:"p95"`
+    Max      float64   `json:"max"`
+    Samples  []float64 `json:"samples"` // 按时间顺序，字节/秒
+}
+
+func newSpeedStats(version, platform, url string, values []float64) speedStats {
+    st := speedStats{Version: version, Platform: platform, URL: url, Samples: values}
+    if len(values) == 0 {
+        st.Samples = []float64{}
+        return st
+    }
+    sorted := append([]float64(nil), values...)
+    sort.Float64s(sorted)
+    st.Min, st.Max = sorted[0], sorted[len(sorted)-1]
+    st.Median = percentile(sorted, 0.5)
+    st.P95 = percentile(sorted, 0.95)
+    return st
+}
+
+// 最近秩法: 不插值，结果总是某个实际样本
+func percentile(sorted []float64, p float64) float64 {
+    i := int(math.Ceil(p*float64(len(sorted)))) - 1
+    return sorted[max(i, 0)]
+}
+
+// 本次任务已输出的分布，-versions 时依次累积，常驻模式每轮重新开始
+var (
+    speedRunsMu sync.Mutex
+    speedRuns   []speedStats
+)
+
+// 清空样本和已累积的分布，与 reportRuns 同时重置
+func resetSpeedSamples() {
+    speedMu.Lock()
+    speedByURL = map[string]*speedSamples{}
+    speedMu.Unlock()
+    speedRunsMu.Lock()
+    speedRuns = nil
+    speedRunsMu.Unlock()
+}
+
+// 输出一个版本各目标的速度分布，并重写 -speed-samples-json
+func reportSpeedSamples(version string, results []*targetResult) error {
+    sorted := append([]*targetResult(nil), results...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].Platform < sorted[j].Platform })
+
+    logf(levelSummary, "\n📈 下载速度 (每 %s 采样，最小/中位数/p95/最大):\n", progressInterval)
+    var stats []speedStats
+    for _, res := range sorted {
+        values := takeSpeedSamples(res.URL)
+        if res.Skipped || res.URL == "" {
+            continue
+        }
+        st := newSpeedStats(version, res.Platform, res.URL, values)
+        stats = append(stats, st)
+        if len(values) == 0 {
+            logf(levelSummary, "   %-14s 样本不足 (下载过快或未下载)\n", res.Platform)
+            continue
+        }
+        logf(levelSummary, "   %-14s %s/s  %s/s  %s/s  %s/s  (%d 个样本)\n", res.Platform,
+            formatBytes(int64(st.Min)), formatBytes(int64(st.Median)), formatBytes(int64(st.P95)),
+            formatBytes(int64(st.Max)), len(values))
+    }
+
+    if opts.SpeedSamplesJSON == "" {
+        return nil
+    }
+    speedRunsMu.Lock()
+    defer speedRunsMu.Unlock()
+    speedRuns = append(speedRuns, stats...)
+    return writeAtomic(opts.SpeedSamplesJSON, func(part string) error {
+        return writeJSONFile(part, speedRuns)
+    })
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// 十六进制 SHA-256 转为 SRI 格式 sha256-<base64>，Nix 的 hash 属性使用同一格式
+func sriHash(hexSum string) (string, error) {
+    b, err := hex.DecodeString(hexSum)
+    if err != nil {
+        return "", err
+    }
+    return "sha256-" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// -emit-sri 收集的目标结果，-versions 时各版本的构建并发追加，整个任务结束后一次写出
+var (
+    sriMu      sync.Mutex
+    sriResults []*targetResult
+)
+
+// 记下一个版本的结果，由 emitSRI 在任务结束后统一写出
+func recordSRI(results []*targetResult) {
+    sriMu.Lock()
+    defer sriMu.Unlock()
+    sriResults = append(sriResults, results...)
+}
+
+// -emit-sri: 每行一个成功目标的 "<压缩包地址> sha256-<base64>"，按版本和平台排序
+// 路径为 - 时在运行结束后输出到标准输出
+func emitSRI() error {
+    sriMu.Lock()
+    sorted := append([]*targetResult(nil), sriResults...)
+    sriMu.Unlock()
+    sort.SliceStable(sorted, func(i, j int) bool {
+        if sorted[i].Version != sorted[j].Version {
+            return versionBefore(sorted[i].Version, sorted[j].Version)
+        }
+        return sorted[i].Platform < sorted[j].Platform
+    })
+
+    var b strings.Builder
+    for _, res := range sorted {
+        // 按 -run-state 跳过的目标没有本轮的源哈希
+        if res.Err != nil || res.SourceSHA256 == "" {
+            continue
+        }
+        sri, err := sriHash(res.SourceSHA256)
+        if err != nil {
+            return err
+        }
+        fmt.Fprintf(&b, "%s %s\n", res.URL, sri)
+    }
+
+    if opts.EmitSRI == "-" {
+        fmt.Print(b.String())
+        return nil
+    }
+    return writeAtomic(opts.EmitSRI, func(part string) error {
+        return os.WriteFile(part, []byte(b.String()), 0o644)
+    })
+}
+
+// 按语义化版本比较，无法解析时按字符串比较
+func versionBefore(a, b string) bool {
+    va, errA := parseSemver(a)
+    vb, errB := parseSemver(b)
+    if errA != nil || errB != nil {
+        return a < b
+    }
+    return va.compare(vb) < 0
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// -version-concurrency 时各版本并发记下结果，结束后一次写出全部版本，按版本和平台排序
+func TestEmitSRIAcrossVersions(t *testing.T) {
+    saved := opts
+    t.Cleanup(func() { opts, sriResults = saved, nil })
+    opts.EmitSRI = filepath.Join(t.TempDir(), "sources.txt")
+    sriResults = nil
+
+    versions := []string{"v20.0.0", "v9.0.0", "v18.1.0"}
+    platforms := []string{"win-x64", "linux-x64"}
+    var wg sync.WaitGroup
+    for _, version := range versions {
+        wg.Add(1)
+        go func(version string) {
+            defer wg.Done()
+            var results []*targetResult
+            for _, platform := range platforms {
+                sum := sha256.Sum256([]byte(version + platform))
+                results = append(results, &targetResult{
+                    Version:      version,
+                    Platform:     platform,
+                    URL:          fmt.Sprintf("https://nodejs.org/dist/%s/node-%s-%s.tar.xz", version, version, platform),
+                    SourceSHA256: hex.EncodeToString(sum[:]),
+                })
+            }
+            // 失败的目标不列出
+            results = append(results, &targetResult{Version: version, Platform: "darwin-arm64", Err: fmt.Errorf("失败")})
+            recordSRI(results)
+        }(version)
+    }
+    wg.Wait()
+    if err := emitSRI(); err != nil {
+        t.Fatal(err)
+    }
+
+    data, err := os.ReadFile(opts.EmitSRI)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got []string
+    for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+        got = append(got, strings.Fields(line)[0])
+    }
+    var want []string
+    for _, version := range []string{"v9.0.0", "v18.1.0", "v20.0.0"} {
+        for _, platform := range []string{"linux-x64", "win-x64"} {
+            want = append(want, fmt.Sprintf("https://nodejs.org/dist/%s/node-%s-%s.tar.xz", version, version, platform))
+        }
+    }
+    if strings.Join(got, "\n") != strings.Join(want, "\n") {
+        t.Fatalf("写出:\n%s\n期望:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+    }
+}
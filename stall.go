@@ -0,0 +1,100 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+// 下载停滞: 最近一个 -stall-window 内的平均速度低于 -min-speed
+// 不是 httpStatusError，按可重试的网络错误处理
+type stallError struct {
+    Speed  float64 // 字节/秒
+    Window time.Duration
+}
+
+func (e *stallError) Error() string {
+    return fmt.Sprintf("下载停滞: 最近 %s 平均 %s/s，低于 -min-speed %s/s",
+        e.Window, formatBytes(int64(e.Speed)), formatBytes(opts.MinSpeed))
+}
+
+// 一次下载尝试已收到的字节数，由 ProgressWriter 累加、看门狗读取
+type stallWatch struct {
+    bytes atomic.Int64
+}
+
+// 未开启停滞检测时 w 为 nil，什么也不做
+func (w *stallWatch) add(n int) {
+    if w != nil {
+        w.bytes.Add(int64(n))
+    }
+}
+
+type stallWatchKey struct{}
+
+// 为一次下载尝试启动看门狗: 每隔 -stall-window 检查这段时间收到的字节数，速度不足时取消返回的 ctx
+// 下载代码用 stallCounter(ctx) 取得计数器交给 ProgressWriter；-min-speed 为 0 时原样返回 ctx
+// stop 必须调用，它结束看门狗，并在该次尝试因停滞而失败时把 err 换成 *stallError
+func watchStall(ctx context.Context) (context.Context, func(err error) error) {
+    if opts.MinSpeed <= 0 {
+        return ctx, func(err error) error { return err }
+    }
+    w := &stallWatch{}
+    ctx, cancel := context.WithCancelCause(ctx)
+    ctx = context.WithValue(ctx, stallWatchKey{}, w)
+    done := make(chan struct{})
+    go func() {
+        t := time.NewTicker(opts.StallWindow)
+        defer t.Stop()
+        var last int64
+        for {
+            select {
+            case <-done:
+                return
+            case <-ctx.Done():
+                return
+            case <-t.C:
+                n := w.bytes.Load()
+                if speed := float64(n-last) / opts.StallWindow.Seconds(); speed < float64(opts.MinSpeed) {
+                    cancel(&stallError{Speed: speed, Window: opts.StallWindow})
+                    return
+                }
+                last = n
+            }
+        }
+    }()
+    return ctx, func(err error) error {
+        close(done)
+        var se *stallError
+        if err != nil && errors.As(context.Cause(ctx), &se) {
+            err = se
+        }
+        cancel(nil)
+        return err
+    }
+}
+
+// watchStall 为本次尝试创建的计数器，未开启停滞检测时为 nil
+func stallCounter(ctx context.Context) *stallWatch {
+    w, _ := ctx.Value(stallWatchKey{}).(*stallWatch)
+    return w
+}
+
+// 停滞后可换用的下载地址: 目标使用主来源时依次为 -cross-check-mirrors 中的同一文件
+// -targets-file 给出 baseURL 的目标不换
+func stallMirrors(version, platform, current string) []string {
+    if o, ok := targetOverrides[platform]; ok && o.BaseURL != "" {
+        return nil
+    }
+    var urls []string
+    for _, base := range opts.CrossCheckMirrors {
+        url := (&distSource{Base: strings.TrimRight(base, "/")}).ArchiveURL(version, platform)
+        if url != current {
+            urls = append(urls, url)
+        }
+    }
+    return urls
+}
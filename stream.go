@@ -0,0 +1,228 @@
+package main
+
+import (
+    "archive/tar"
+    "bufio"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "hash"
+    "io"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// 数据本身有问题 (校验和、架构不符)，换用分步处理也不会成功
+type permanentError struct{ error }
+
+func (e *permanentError) Unwrap() error { return e.error }
+
+// 是否走流式处理: 只支持从网络获取的 tar.xz/tar.gz，并且只输出压缩后的 node 可执行文件
+// -verify-version 需要完整的可执行文件，-segments 需要先把各段写进文件，仍走分步处理
+func streamable(platform string) bool {
+    return !strings.HasPrefix(platform, "win") && opts.SourceDir == "" &&
+        !opts.RawBinary && len(opts.Extra) == 0 && !opts.VerifyVersion && opts.Segments <= 1
+}
+
+// 流式处理单个 tar 目标: 响应体 -> xz/gzip -> tar -> node -> 压缩 -> 输出，不落中间文件
+// 源压缩包和输出的 SHA-256 在同一遍读写中计算；三个阶段同时进行，按固定顺序占用三个名额
+func processTargetStreaming(ctx context.Context, res *targetResult, outFile, platform string) (err error) {
+    slot, err := acquireDownload(ctx)
+    if err != nil {
+        return err
+    }
+    defer slot.release()
+    if err := checkDeadline(); err != nil {
+        return err
+    }
+    if err := waitForSpace(ctx, platform); err != nil {
+        return &permanentError{err}
+    }
+    // 响应体读完即算下载成功，后续解压、压缩、校验的错误与镜像无关
+    defer func() {
+        var dl *DownloadError
+        if errors.As(err, &dl) {
+            slot.report(dl.Err)
+        } else {
+            slot.report(nil)
+        }
+    }()
+    releaseExtract, err := acquire(ctx, extractSem)
+    if err != nil {
+        return err
+    }
+    defer releaseExtract()
+    releaseCompress, err := acquire(ctx, compressSem)
+    if err != nil {
+        return err
+    }
+    defer releaseCompress()
+
+    logf(levelPhase, "\n⬇️  流式处理 %s -> %s\n", res.URL, outFile)
+    attemptCtx := ctx
+    if t := targetTimeout(platform); t > 0 {
+        var cancel context.CancelFunc
+        attemptCtx, cancel = context.WithTimeout(ctx, t)
+        defer cancel()
+    }
+    attemptCtx, stopStall := watchStall(attemptCtx)
+    defer func() {
+        if serr := stopStall(err); serr != err {
+            err = &DownloadError{Platform: platform, URL: res.URL, Err: serr}
+        }
+    }()
+    req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, res.URL, nil)
+    if err != nil {
+        return &DownloadError{Platform: platform, URL: res.URL, Err: err}
+    }
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return &DownloadError{Platform: platform, URL: res.URL, Err: err}
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return &DownloadError{Platform: platform, URL: res.URL, Err: newHTTPStatusError(resp)}
+    }
+
+    srcHash := sha256.New()
+    pw := &ProgressWriter{Phase: PhaseDownload, Subject: platform, Total: resp.ContentLength, Samples: speedRecorder(res.URL), Stall: stallCounter(attemptCtx)}
+    rb := &bodyReader{r: resp.Body}
+    body := io.TeeReader(rb, io.MultiWriter(srcHash, pw))
+
+    // 各阶段都在读响应体，响应体读取出错时一律算作下载错误
+    stageErr := func(err error, wrap func(error) error) error {
+        if rb.err != nil {
+            return &DownloadError{Platform: platform, URL: res.URL, Err: rb.err}
+        }
+        return wrap(err)
+    }
+    extractErr := func(err error) error { return &ExtractError{Platform: platform, URL: res.URL, Err: err} }
+
+    tarr, err := tarStream(body, archiveFormat(platform))
+    if err != nil {
+        return stageErr(err, extractErr)
+    }
+    tr := tar.NewReader(tarr)
+    xo := extractOptions()
+    h, err := xo.nextNodeMember(tr)
+    if err != nil {
+        if errors.Is(err, errNoNodeMember) || errors.Is(err, errUnsafeMember) {
+            return &permanentError{extractErr(err)}
+        }
+        return stageErr(err, extractErr)
+    }
+    member, err := xo.limitMember(h.Name, h.Size, tr)
+    if err != nil {
+        return &permanentError{extractErr(err)}
+    }
+
+    br := bufio.NewReader(member)
+    hdr, _ := br.Peek(64)
+    if err := verifyArchHeader(hdr, platform); err != nil {
+        return &permanentError{extractErr(err)}
+    }
+    var bin io.Reader = br
+    var binHash hash.Hash
+    if opts.Dedupe {
+        binHash = sha256.New()
+        bin = io.TeeReader(br, binHash)
+    }
+
+    start := time.Now()
+    err = writeCompressed(outFile, func(part string) error {
+        out, err := os.Create(part)
+        if err != nil {
+            return err
+        }
+        defer out.Close()
+        var side io.Writer
+        if opts.AlsoGzip {
+            f, err := os.Create(gzipPart(part))
+            if err != nil {
+                return err
+            }
+            defer f.Close()
+            side = f
+        }
+        outHash := sha256.New()
+        if err := compressStream(io.MultiWriter(out, outHash), bin, side, platform); err != nil {
+            return err
+        }
+        if err := xo.ensureSingleNodeMember(tr, h.Name); err != nil {
+            return stageErr(err, func(err error) error { return &permanentError{extractErr(err)} })
+        }
+        // node 之后的成员不需要解压，但源压缩包的哈希要覆盖完整响应体
+        if _, err := io.Copy(io.Discard, body); err != nil {
+            return err
+        }
+        if err := out.Close(); err != nil {
+            return err
+        }
+        pw.Done()
+        res.SourceSHA256 = hex.EncodeToString(srcHash.Sum(nil))
+        res.OutputSHA256 = hex.EncodeToString(outHash.Sum(nil))
+
+        if opts.Checksum {
+            sumsURL := targetSource(platform).ChecksumURL(res.Version)
+            sums, err := remoteShasums(ctx, sumsURL).wait(ctx)
+            if err != nil {
+                err = shasumsError(platform, sumsURL, err)
+                var ce *ChecksumError
+                if errors.As(err, &ce) {
+                    return &permanentError{err}
+                }
+                return err
+            }
+            if err := verifyChecksum(sums, archiveName(res.Version, platform), res.SourceSHA256, platform); err != nil {
+                return &permanentError{&ChecksumError{Platform: platform, URL: res.URL, Err: err}}
+            }
+        }
+        return nil
+    })
+    res.CompressTime = time.Since(start)
+    var dlErr *DownloadError
+    var perm *permanentError
+    if errors.Is(err, errExtractTooLarge) && !errors.As(err, &perm) {
+        err = &permanentError{extractErr(err)}
+    }
+    if err != nil && !errors.As(err, &dlErr) && !errors.As(err, &perm) {
+        err = stageErr(err, func(err error) error { return &CompressError{Platform: platform, URL: res.URL, Err: err} })
+    }
+    if err != nil {
+        return err
+    }
+    res.SourceBytes = pw.Written
+    if binHash != nil {
+        res.BinarySHA256 = hex.EncodeToString(binHash.Sum(nil))
+    }
+    logf(levelPhase, "解压[%s] bin/node 并压缩完成\n", platform)
+    return nil
+}
+
+// 记录响应体的首个读取错误 (EOF 除外)，用于区分网络中断和解压、压缩错误
+type bodyReader struct {
+    r   io.Reader
+    err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+    n, err := b.r.Read(p)
+    if err != nil && err != io.EOF && b.err == nil {
+        b.err = err
+    }
+    return n, err
+}
+
+// 流式处理失败后能否改用分步处理: 网络中断等可重试的错误交给分步处理的续传和重试
+func streamFallback(ctx context.Context, err error) bool {
+    // 校验和不匹配且配置了备用镜像时，交给分步处理去对比各镜像
+    var mm *checksumMismatch
+    if len(opts.CrossCheckMirrors) > 0 && errors.As(err, &mm) {
+        return ctx.Err() == nil
+    }
+    var perm *permanentError
+    return ctx.Err() == nil && !errors.As(err, &perm) && !errors.Is(err, errDeadline) && retryable(err)
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package main
+
+import (
+    "os"
+    "path/filepath"
+)
+
+// 原子地把符号链接 live 指向 release: 先建临时链接再 rename 覆盖，读者看到的要么是旧目录要么是新目录
+// 保留上一个版本目录，正在读取旧文件的请求不会中断；更早的版本目录被删除
+// live 原来是普通目录时 (首次使用本模式) 无法原子转换: 空目录直接删除，非空目录先移入 <out>.releases
+func publishDir(live, release string) error {
+    var previous string
+    fi, err := os.Lstat(live)
+    switch {
+    case err == nil && fi.Mode()&os.ModeSymlink != 0:
+        if target, err := os.Readlink(live); err == nil {
+            previous = target
+        }
+    case err == nil:
+        if err := os.Remove(live); err != nil {
+            previous = filepath.Join(live+".releases", "initial")
+            logf(levelError, "⚠️  %s 是普通目录，首次切换时移入 %s，期间短暂不可用\n", live, previous)
+            if err := os.Rename(live, previous); err != nil {
+                return err
+            }
+        }
+    case !os.IsNotExist(err):
+        return err
+    }
+
+    target, err := filepath.Rel(filepath.Dir(live), release)
+    if err != nil {
+        target = release
+    }
+    tmp := live + ".link.tmp"
+    os.Remove(tmp)
+    if err := os.Symlink(target, tmp); err != nil {
+        return err
+    }
+    if err := os.Rename(tmp, live); err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    pruneReleases(live, release, previous)
+    return nil
+}
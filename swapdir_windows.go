@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "sync"
+    "time"
+)
+
+var swapWarnOnce sync.Once
+
+// Windows 上建符号链接需要特权，且目录不能 rename 覆盖已存在的目录:
+// 退化为 旧目录改名 -> 新目录改名到位 -> 删除旧目录，两次改名之间 live 短暂不存在
+func publishDir(live, release string) error {
+    swapWarnOnce.Do(func() {
+        logf(levelError, "⚠️  Windows 上 -replace-existing-atomic 切换目录时有短暂的不可用窗口\n")
+    })
+    old := fmt.Sprintf("%s.old-%d", live, time.Now().UnixNano())
+    if err := os.Rename(longPath(live), longPath(old)); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    if err := os.Rename(longPath(release), longPath(live)); err != nil {
+        // 尽量恢复旧目录
+        os.Rename(longPath(old), longPath(live))
+        return err
+    }
+    os.RemoveAll(longPath(old))
+    pruneReleases(live)
+    return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/klauspost/compress/zstd"
+)
+
+// -targets-file 中的一项，timeout/retries/baseURL/zstdLevel 可选，未给出时沿用全局设置
+type targetEntry struct {
+    Output    string   `json:"output"`
+    Platform  string   `json:"platform"`
+    BaseURL   string   `json:"baseURL,omitempty"`
+    Retries   *int     `json:"retries,omitempty"`
+    Timeout   duration `json:"timeout,omitempty"`
+    ZstdLevel string   `json:"zstdLevel,omitempty"`
+}
+
+// JSON 中以 "10m" 这样的字符串表示的时长
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+    var s string
+    if err := json.Unmarshal(b, &s); err != nil {
+        return fmt.Errorf("timeout 应为字符串，如 \"10m\"")
+    }
+    v, err := time.ParseDuration(s)
+    if err != nil {
+        return fmt.Errorf("timeout 无效: %w", err)
+    }
+    *d = duration(v)
+    return nil
+}
+
+// 按平台记录的覆盖项，只包含 targets 文件中给出了覆盖字段的平台
+var targetOverrides = map[string]targetEntry{}
+
+// 读取 -targets-file，替换内置的 targets 表
+// 格式为 JSON 数组，未知字段、重复的输出名或平台都会报错
+func loadTargetsFile(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    var raw []json.RawMessage
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return fmt.Errorf("%s: 应为 JSON 数组: %w", path, err)
+    }
+    if len(raw) == 0 {
+        return fmt.Errorf("%s: 没有任何目标", path)
+    }
+
+    loaded := make(map[string]string, len(raw))
+    overrides := map[string]targetEntry{}
+    seen := map[string]bool{}
+    for i, item := range raw {
+        var e targetEntry
+        dec := json.NewDecoder(bytes.NewReader(item))
+        dec.DisallowUnknownFields()
+        if err := dec.Decode(&e); err != nil {
+            return fmt.Errorf("%s 第 %d 项: %w", path, i+1, err)
+        }
+        switch {
+        case e.Output == "" || e.Platform == "":
+            return fmt.Errorf("%s 第 %d 项: output 和 platform 不能为空", path, i+1)
+        case strings.ContainsAny(e.Output, `/\`):
+            return fmt.Errorf("%s 第 %d 项: output 只能是文件名: %q", path, i+1, e.Output)
+        case loaded[e.Output] != "":
+            return fmt.Errorf("%s 第 %d 项: 重复的 output %q", path, i+1, e.Output)
+        case seen[e.Platform]:
+            return fmt.Errorf("%s 第 %d 项: 重复的 platform %q", path, i+1, e.Platform)
+        case e.Retries != nil && *e.Retries < 0:
+            return fmt.Errorf("%s 第 %d 项: retries 不能为负数", path, i+1)
+        case e.Timeout < 0:
+            return fmt.Errorf("%s 第 %d 项: timeout 不能为负数", path, i+1)
+        }
+        if e.ZstdLevel != "" {
+            if ok, _ := zstd.EncoderLevelFromString(e.ZstdLevel); !ok {
+                return fmt.Errorf("%s 第 %d 项: zstdLevel 只能是 fastest、default、better、best: %q", path, i+1, e.ZstdLevel)
+            }
+        }
+        loaded[e.Output] = e.Platform
+        seen[e.Platform] = true
+        if e.BaseURL != "" || e.Retries != nil || e.Timeout > 0 || e.ZstdLevel != "" {
+            overrides[e.Platform] = e
+        }
+    }
+    targets, targetOverrides = loaded, overrides
+    return nil
+}
+
+// 目标的最大重试次数
+func targetRetries(platform string) int {
+    if o, ok := targetOverrides[platform]; ok && o.Retries != nil {
+        return *o.Retries
+    }
+    return opts.Retries
+}
+
+// 目标每次下载尝试的超时，0 表示不限制
+func targetTimeout(platform string) time.Duration {
+    return time.Duration(targetOverrides[platform].Timeout)
+}
+
+// 压缩输出解压后应使用的文件名，写入 .meta、.provenance.json 和 versions.json 的 originalName
+// Windows 目标按 -windows-exe-name 为 node.exe 或 node，其他平台为 node；
+// -extra/-extract-dir 的输出是 tar 包、-raw-binary 的输出就是最终文件，都返回空
+func originalName(platform string) string {
+    if len(opts.Extra) > 0 || opts.ExtractDir != "" || opts.RawBinary {
+        return ""
+    }
+    if strings.HasPrefix(platform, "win") && opts.WindowsExeName == "keep" {
+        return "node.exe"
+    }
+    return "node"
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "time"
+)
+
+const releasesAPI = "https://api.github.com/repos/sinspired/update-sub-store-node/releases/latest"
+
+// 在后台检查工具自身是否有新版本，发现新版本时从 channel 返回其 tag；
+// 未注入版本号、网络失败或解析失败都静默忽略，不影响正常构建
+func startUpdateCheck(ctx context.Context) <-chan string {
+    ch := make(chan string, 1)
+    go func() {
+        defer close(ch)
+        current, err := parseSemver(toolVersion)
+        if err != nil {
+            return
+        }
+        ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+        defer cancel()
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesAPI, nil)
+        if err != nil {
+            return
+        }
+        req.Header.Set("Accept", "application/vnd.github+json")
+        resp, err := httpClient.Do(req)
+        if err != nil {
+            return
+        }
+        defer resp.Body.Close()
+        if resp.StatusCode != http.StatusOK {
+            return
+        }
+
+        var release struct {
+            TagName string `json:"tag_name"`
+        }
+        if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+            return
+        }
+        latest, err := parseSemver(release.TagName)
+        if err == nil && latest.compare(current) > 0 {
+            ch <- release.TagName
+        }
+    }()
+    return ch
+}
+
+// 输出更新提示；检查尚未完成时直接跳过，不等待
+func reportUpdate(ch <-chan string) {
+    if ch == nil {
+        return
+    }
+    select {
+    case tag := <-ch:
+        if tag != "" {
+            logf(levelSummary, "💡 %s 有新版本 %s (当前 %s): https://github.com/sinspired/update-sub-store-node/releases\n",
+                toolName, tag, toolVersion)
+        }
+    default:
+    }
+}
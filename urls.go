@@ -0,0 +1,118 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "net/url"
+    "strings"
+    "text/template"
+)
+
+const defaultMirror = "https://nodejs.org/dist"
+
+// 镜像预设，同时给出 dist 根地址和 index.json 地址
+type mirrorPreset struct {
+    Dist  string
+    Index string
+}
+
+var mirrorPresets = map[string]mirrorPreset{
+    "nodejs": {defaultMirror, defaultMirror + "/index.json"},
+    "taobao": {"https://npmmirror.com/mirrors/node", "https://npmmirror.com/mirrors/node/index.json"},
+    "tuna":   {"https://mirrors.tuna.tsinghua.edu.cn/nodejs-release", "https://mirrors.tuna.tsinghua.edu.cn/nodejs-release/index.json"},
+}
+
+// 应用 -mirror-preset，不能与显式的 -mirror/-index-url 混用
+func applyMirrorPreset(explicit map[string]bool) error {
+    if opts.MirrorPreset == "" {
+        return nil
+    }
+    p, ok := mirrorPresets[opts.MirrorPreset]
+    if !ok {
+        return fmt.Errorf("未知的 -mirror-preset %q，可选: nodejs, taobao, tuna", opts.MirrorPreset)
+    }
+    if explicit["mirror"] || explicit["index-url"] {
+        return fmt.Errorf("-mirror-preset 不能与 -mirror/-index-url 同时使用")
+    }
+    opts.Mirror, opts.IndexURL = p.Dist, p.Index
+    return nil
+}
+
+const defaultArchiveTemplate = "node-{{.Version}}-{{.Platform}}{{.Ext}}"
+
+// 压缩包文件名模板，由 -archive-template 指定
+var archiveTmpl = template.Must(template.New("archive").Parse(defaultArchiveTemplate))
+
+type archiveData struct {
+    Version  string
+    Platform string
+    Ext      string
+}
+
+// 解析并试渲染 -archive-template，模板有误时在启动阶段报错
+func initArchiveTemplate() error {
+    t, err := template.New("archive").Option("missingkey=error").Parse(opts.ArchiveTemplate)
+    if err != nil {
+        return fmt.Errorf("-archive-template 解析失败: %w", err)
+    }
+    var buf bytes.Buffer
+    if err := t.Execute(&buf, archiveData{Version: "v0.0.0", Platform: "linux-x64", Ext: ".tar.xz"}); err != nil {
+        return fmt.Errorf("-archive-template 渲染失败: %w", err)
+    }
+    if buf.Len() == 0 || strings.Contains(buf.String(), "/") {
+        return fmt.Errorf("-archive-template 必须渲染为非空文件名: %q", buf.String())
+    }
+    archiveTmpl = t
+    return nil
+}
+
+func archiveExt(platform string) string {
+    return "." + archiveFormat(platform).String()
+}
+
+func archiveName(version, platform string) string {
+    var buf bytes.Buffer
+    // 模板已在启动时验证过
+    archiveTmpl.Execute(&buf, archiveData{Version: version, Platform: platform, Ext: archiveExt(platform)})
+    return buf.String()
+}
+
+func mirrorBase() string {
+    return strings.TrimRight(opts.Mirror, "/")
+}
+
+// 版本目录: <base>[/<release-path>]/<version>
+func releaseDir(base, version string) string {
+    if p := strings.Trim(opts.ReleasePath, "/"); p != "" {
+        return base + "/" + p + "/" + version
+    }
+    return base + "/" + version
+}
+
+// 用示例版本拼出完整地址并检查格式，镜像地址、-release-path 或 -source 的配置有误时在启动阶段报错
+func validateReleaseURL() error {
+    for _, sample := range []string{buildURL("v0.0.0", "linux-x64"), releaseSource.ChecksumURL("v0.0.0")} {
+        u, err := url.Parse(sample)
+        if err != nil {
+            return fmt.Errorf("下载地址无效: %w", err)
+        }
+        if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("下载地址无效: %q (需要 http(s)://host/...)", sample)
+        }
+        if strings.Contains(strings.TrimPrefix(u.Path, "/"), "//") {
+            return fmt.Errorf("下载地址中有空的路径段: %q", sample)
+        }
+    }
+    return nil
+}
+
+// -cross-check-mirrors 中的每一项都必须是 http(s) 地址
+func validateCrossCheckMirrors() error {
+    for _, m := range opts.CrossCheckMirrors {
+        u, err := url.Parse(m)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("-cross-check-mirrors 中的地址无效: %q", m)
+        }
+    }
+    return nil
+}
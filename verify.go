@@ -0,0 +1,100 @@
+package main
+
+import (
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// -verify-only: 按 -out 目录中 -checksum-algo 对应的校验和文件校验已有输出，并确认每个 .zst/.br 能完整解压
+// 不下载也不重新构建，返回退出码
+func runVerifyOnly() int {
+    algo := outputChecksumAlgo()
+    f, err := os.Open(filepath.Join(opts.Out, outputShasumsName()))
+    if err != nil {
+        logf(levelError, "❌ 读取校验和文件失败: %v\n", err)
+        return exitFailure
+    }
+    sums, err := parseShasums(f)
+    f.Close()
+    if err != nil {
+        logf(levelError, "❌ 解析校验和文件失败: %v\n", err)
+        return exitFailure
+    }
+
+    names := make([]string, 0, len(sums))
+    for name := range sums {
+        names = append(names, name)
+    }
+    // 目录中存在但未列出的压缩输出也算失败，其他 -prefix 的输出除外
+    entries, _ := os.ReadDir(opts.Out)
+    for _, e := range entries {
+        if _, ok := sums[e.Name()]; !ok && isCompressedOutput(e.Name()) && strings.HasPrefix(e.Name(), opts.Prefix) {
+            names = append(names, e.Name())
+        }
+    }
+    sort.Strings(names)
+
+    failed := 0
+    for _, name := range names {
+        if err := verifyOutput(filepath.Join(opts.Out, name), sums[name], algo.New); err != nil {
+            failed++
+            logf(levelError, "❌ %s: %v\n", name, err)
+        } else {
+            logf(levelSummary, "✅ %s\n", name)
+        }
+    }
+    logf(levelSummary, "\n🔍 校验完成: 通过 %d，失败 %d\n", len(names)-failed, failed)
+    return exitCode(len(names), failed)
+}
+
+func isCompressedOutput(name string) bool {
+    _, ok := compressorByExt(name)
+    return ok
+}
+
+// 校验文件哈希，压缩输出同时完整解压一遍确认数据流无损
+func verifyOutput(path, want string, newHash func() hash.Hash) error {
+    if want == "" {
+        return fmt.Errorf("未在校验和文件中列出")
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    var size int64
+    if info, err := f.Stat(); err == nil {
+        size = info.Size()
+    }
+    h := newHash()
+    pw := &ProgressWriter{Phase: PhaseVerify, Subject: filepath.Base(path), Total: size}
+    var r io.Reader = io.TeeReader(f, io.MultiWriter(h, pw))
+    if c, ok := compressorByExt(path); ok {
+        dec, err := c.NewReader(r)
+        if err != nil {
+            return err
+        }
+        if _, err := io.Copy(io.Discard, dec); err != nil {
+            return fmt.Errorf("解压失败: %w", err)
+        }
+        // 解压器可能没有读完末尾数据，补齐以计算完整哈希
+        if _, err := io.Copy(io.Discard, r); err != nil {
+            return err
+        }
+    } else if _, err := io.Copy(io.Discard, r); err != nil {
+        return err
+    }
+    pw.Done()
+
+    if got := hex.EncodeToString(h.Sum(nil)); got != want {
+        return fmt.Errorf("校验和不匹配: 期望 %s，实际 %s", want, got)
+    }
+    return nil
+}
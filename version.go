@@ -0,0 +1,351 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+type NodeVersion struct {
+    Version string      `json:"version"`
+    LTS     interface{} `json:"lts"`
+    Date    string      `json:"date"`
+}
+
+// index.json 中 date 字段的格式，-since-date 使用同样的格式
+const releaseDateLayout = "2006-01-02"
+
+// 发布日期，date 缺失或格式不对时 ok 为 false
+func (v NodeVersion) released() (time.Time, bool) {
+    t, err := time.Parse(releaseDateLayout, v.Date)
+    return t, err == nil
+}
+
+// -max-age: 选出的版本发布时间过早时，镜像或 index.json 可能已停止更新
+// 默认只警告，-strict 时作为失败返回
+func checkFreshness(versions []NodeVersion, version string) error {
+    if opts.MaxAge <= 0 {
+        return nil
+    }
+    for _, v := range versions {
+        if v.Version != version {
+            continue
+        }
+        released, ok := v.released()
+        if !ok {
+            logf(levelError, "⚠️  %s 在 index.json 中没有发布日期，无法按 -max-age 检查\n", version)
+            return nil
+        }
+        age := time.Since(released)
+        if age <= opts.MaxAge {
+            return nil
+        }
+        msg := fmt.Sprintf("%s 发布于 %s，已有 %d 天，超过 -max-age %s，镜像或 index.json 可能已过期",
+            version, v.Date, int(age.Hours()/24), formatAge(opts.MaxAge))
+        if opts.Strict {
+            return errors.New(msg)
+        }
+        logf(levelError, "⚠️  %s\n", msg)
+        return nil
+    }
+    return nil
+}
+
+// 按天解析时长，如 90d，也接受 time.ParseDuration 的格式
+func parseAge(v string) (time.Duration, error) {
+    s := strings.TrimSpace(v)
+    if days, ok := strings.CutSuffix(s, "d"); ok {
+        n, err := strconv.Atoi(days)
+        if err != nil || n < 0 {
+            return 0, fmt.Errorf("无效的时长: %q", v)
+        }
+        return time.Duration(n) * 24 * time.Hour, nil
+    }
+    d, err := time.ParseDuration(s)
+    if err != nil || d < 0 {
+        return 0, fmt.Errorf("无效的时长: %q (如 90d、36h)", v)
+    }
+    return d, nil
+}
+
+func formatAge(d time.Duration) string {
+    if d%(24*time.Hour) == 0 {
+        return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+    }
+    return d.String()
+}
+
+// LTS 代号，非 LTS 版本返回空串 (index.json 中 lts 为 false 或代号字符串)
+func (v NodeVersion) ltsName() string {
+    name, _ := v.LTS.(string)
+    return name
+}
+
+// 版本选择条件，各字段同时生效
+type versionCriteria struct {
+    Channel string    // lts (只选 LTS) 或 current (不限)
+    LTSName string    // LTS 代号，如 iron，不区分大小写
+    Range   string    // semver 范围，如 "20.x"、">=18 <22"
+    Since   time.Time // 只选该日期及之后发布的版本，零值表示不限制
+
+    AllowPrereleaseLTS bool // 把 LTSName 所在主版本中尚未标记 LTS 的版本也视为该代号
+}
+
+func criteriaFromOptions() versionCriteria {
+    return versionCriteria{
+        Channel: opts.Channel,
+        LTSName: opts.LTSName,
+        Range:   opts.VersionRange,
+        Since:   opts.Since,
+
+        AllowPrereleaseLTS: opts.AllowPrereleaseLTS,
+    }
+}
+
+// 从按新到旧排列的 versions 中选出第一个满足条件的版本
+func selectVersion(versions []NodeVersion, c versionCriteria) (string, error) {
+    matched, err := matchVersions(versions, c, 1)
+    if err != nil {
+        return "", err
+    }
+    return matched[0], nil
+}
+
+// 按原顺序返回满足条件的版本，limit > 0 时最多返回 limit 个；没有满足条件的版本时返回错误
+func matchVersions(versions []NodeVersion, c versionCriteria, limit int) ([]string, error) {
+    var rng []comparator
+    if c.Range != "" {
+        var err error
+        if rng, err = parseRange(c.Range); err != nil {
+            return nil, err
+        }
+    }
+
+    preMajor := 0
+    if c.AllowPrereleaseLTS && c.LTSName != "" {
+        major, ok := ltsMajor(versions, c.LTSName)
+        if !ok {
+            return nil, fmt.Errorf("-allow-prerelease-lts: 无法确定 LTS 代号 %q 对应的主版本", c.LTSName)
+        }
+        preMajor = major
+    }
+
+    var matched []string
+    for _, v := range versions {
+        name := v.ltsName()
+        prerelease := false
+        if name == "" && preMajor > 0 {
+            if sv, err := parseSemver(v.Version); err == nil && sv[0] == preMajor {
+                name, prerelease = c.LTSName, true
+            }
+        }
+        if (c.Channel == "lts" || c.LTSName != "") && name == "" {
+            continue
+        }
+        if c.LTSName != "" && !strings.EqualFold(name, c.LTSName) {
+            continue
+        }
+        if rng != nil {
+            sv, err := parseSemver(v.Version)
+            if err != nil || !matchRange(sv, rng) {
+                continue
+            }
+        }
+        if !c.Since.IsZero() {
+            // 缺少发布日期的版本无法判断，不选
+            if t, ok := v.released(); !ok || t.Before(c.Since) {
+                continue
+            }
+        }
+        if prerelease {
+            logf(levelSummary, "⚠️  %s 尚未被标记为 LTS (%s)，按 -allow-prerelease-lts 选用\n", v.Version, c.LTSName)
+        }
+        matched = append(matched, v.Version)
+        if limit > 0 && len(matched) == limit {
+            break
+        }
+    }
+    if len(matched) == 0 {
+        return nil, fmt.Errorf("没有满足条件的版本: %s", c)
+    }
+    return matched, nil
+}
+
+// 已知 LTS 代号对应的主版本，用于在 index.json 标记之前识别即将转为 LTS 的版本线
+var ltsLines = map[string]int{
+    "argon":    4,
+    "boron":    6,
+    "carbon":   8,
+    "dubnium":  10,
+    "erbium":   12,
+    "fermium":  14,
+    "gallium":  16,
+    "hydrogen": 18,
+    "iron":     20,
+    "jod":      22,
+    "krypton":  24,
+}
+
+// LTS 代号对应的主版本: 优先取 index.json 中已标记该代号的版本，否则查内置表
+func ltsMajor(versions []NodeVersion, name string) (int, bool) {
+    for _, v := range versions {
+        if strings.EqualFold(v.ltsName(), name) {
+            if sv, err := parseSemver(v.Version); err == nil {
+                return sv[0], true
+            }
+        }
+    }
+    major, ok := ltsLines[strings.ToLower(name)]
+    return major, ok
+}
+
+func (c versionCriteria) String() string {
+    s := fmt.Sprintf("channel=%s lts-name=%q range=%q", c.Channel, c.LTSName, c.Range)
+    if !c.Since.IsZero() {
+        s += " since-date=" + c.Since.Format(releaseDateLayout)
+    }
+    return s
+}
+
+type semver [3]int
+
+// 带 v 前缀的完整版本号，与 index.json 和下载路径中的形式相同，如 v20.11.0
+var versionRe = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+// 规范化用户给出的版本号: 缺少 v 前缀时补上，并校验格式
+func normalizeVersion(s string) (string, error) {
+    s = strings.TrimSpace(s)
+    if !strings.HasPrefix(s, "v") {
+        s = "v" + s
+    }
+    if !versionRe.MatchString(s) {
+        return "", fmt.Errorf("无效的版本号: %q (应形如 v20.11.0)", s)
+    }
+    return s, nil
+}
+
+// 解析 v20.11.0 / 20.11.0 形式的版本号
+func parseSemver(s string) (semver, error) {
+    var v semver
+    parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+    if len(parts) != 3 {
+        return v, fmt.Errorf("无效的版本号: %q", s)
+    }
+    for i, p := range parts {
+        n, err := strconv.Atoi(p)
+        if err != nil || n < 0 {
+            return v, fmt.Errorf("无效的版本号: %q", s)
+        }
+        v[i] = n
+    }
+    return v, nil
+}
+
+func (a semver) compare(b semver) int {
+    for i := range a {
+        if a[i] != b[i] {
+            if a[i] < b[i] {
+                return -1
+            }
+            return 1
+        }
+    }
+    return 0
+}
+
+// 单个比较条件，如 >=20.1.0；部分版本号 (20、20.x) 会展开为区间
+type comparator struct {
+    op string
+    v  semver
+}
+
+// 解析以空格分隔的比较条件，所有条件同时满足才算匹配
+func parseRange(s string) ([]comparator, error) {
+    var out []comparator
+    for _, f := range strings.Fields(s) {
+        op := ""
+        for _, p := range []string{">=", "<=", ">", "<", "="} {
+            if strings.HasPrefix(f, p) {
+                op, f = p, f[len(p):]
+                break
+            }
+        }
+        cs, err := expandPartial(op, f)
+        if err != nil {
+            return nil, fmt.Errorf("无效的版本范围 %q: %w", s, err)
+        }
+        out = append(out, cs...)
+    }
+    if len(out) == 0 {
+        return nil, fmt.Errorf("无效的版本范围 %q", s)
+    }
+    return out, nil
+}
+
+// 展开部分版本号: "20" / "20.x" 表示 [20.0.0, 21.0.0)，"20.11" 表示 [20.11.0, 20.12.0)
+func expandPartial(op, s string) ([]comparator, error) {
+    s = strings.TrimPrefix(s, "v")
+    parts := strings.Split(s, ".")
+    var nums []int
+    for _, p := range parts {
+        if p == "x" || p == "*" {
+            break
+        }
+        n, err := strconv.Atoi(p)
+        if err != nil || n < 0 {
+            return nil, fmt.Errorf("无效的版本号 %q", s)
+        }
+        nums = append(nums, n)
+    }
+    if len(nums) == 0 || len(nums) > 3 {
+        return nil, fmt.Errorf("无效的版本号 %q", s)
+    }
+
+    var lo semver
+    copy(lo[:], nums)
+    if len(nums) == 3 {
+        if op == "" {
+            op = "="
+        }
+        return []comparator{{op, lo}}, nil
+    }
+
+    hi := lo
+    hi[len(nums)-1]++
+    switch op {
+    case "", "=":
+        return []comparator{{">=", lo}, {"<", hi}}, nil
+    case ">":
+        return []comparator{{">=", hi}}, nil
+    case "<=":
+        return []comparator{{"<", hi}}, nil
+    default:
+        return []comparator{{op, lo}}, nil
+    }
+}
+
+func matchRange(v semver, rng []comparator) bool {
+    for _, c := range rng {
+        d := v.compare(c.v)
+        ok := false
+        switch c.op {
+        case ">=":
+            ok = d >= 0
+        case ">":
+            ok = d > 0
+        case "<=":
+            ok = d <= 0
+        case "<":
+            ok = d < 0
+        case "=":
+            ok = d == 0
+        }
+        if !ok {
+            return false
+        }
+    }
+    return true
+}
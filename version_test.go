@@ -0,0 +1,111 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+// 按 index.json 的顺序从新到旧排列
+var fixtureVersions = []NodeVersion{
+    {Version: "v23.1.0", LTS: false, Date: "2024-10-24"},
+    {Version: "v22.11.0", LTS: "Jod", Date: "2024-10-29"},
+    {Version: "v22.10.0", LTS: false, Date: "2024-10-16"},
+    {Version: "v21.7.3", LTS: false, Date: "2024-04-10"},
+    {Version: "v20.18.0", LTS: "Iron", Date: "2024-10-03"},
+    {Version: "v20.11.0", LTS: "Iron", Date: "2024-01-09"},
+    {Version: "v18.20.4", LTS: "Hydrogen", Date: "2024-07-08"},
+    {Version: "v18.0.0", LTS: false},
+}
+
+func TestSelectVersion(t *testing.T) {
+    tests := []struct {
+        name string
+        c    versionCriteria
+        want string
+    }{
+        {"最新 LTS", versionCriteria{Channel: "lts"}, "v22.11.0"},
+        {"current 不限 LTS", versionCriteria{Channel: "current"}, "v23.1.0"},
+        {"按代号", versionCriteria{Channel: "lts", LTSName: "iron"}, "v20.18.0"},
+        {"代号不区分大小写", versionCriteria{LTSName: "HYDROGEN"}, "v18.20.4"},
+        {"semver 范围", versionCriteria{Channel: "current", Range: "20.x"}, "v20.18.0"},
+        {"范围上下界", versionCriteria{Channel: "current", Range: ">=21 <22"}, "v21.7.3"},
+        {"范围与 LTS 同时生效", versionCriteria{Channel: "lts", Range: "<20.18.0"}, "v20.11.0"},
+        {"发布日期", versionCriteria{Channel: "lts", Since: time.Date(2024, 10, 10, 0, 0, 0, 0, time.UTC)}, "v22.11.0"},
+        {"尚未标记的 LTS", versionCriteria{LTSName: "jod", AllowPrereleaseLTS: true, Range: "<22.11.0"}, "v22.10.0"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := selectVersion(fixtureVersions, tt.c)
+            if err != nil {
+                t.Fatalf("selectVersion(%s): %v", tt.c, err)
+            }
+            if got != tt.want {
+                t.Fatalf("selectVersion(%s) = %s，期望 %s", tt.c, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestSelectVersionNoMatch(t *testing.T) {
+    for _, c := range []versionCriteria{
+        {Channel: "lts", Range: "21.x"},
+        {LTSName: "argon"},
+        {Channel: "current", Since: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+    } {
+        if got, err := selectVersion(fixtureVersions, c); err == nil {
+            t.Errorf("selectVersion(%s) = %s，期望没有满足条件的版本", c, got)
+        }
+    }
+    if _, err := selectVersion(fixtureVersions, versionCriteria{Channel: "current", Range: ">=abc"}); err == nil {
+        t.Errorf("无效的范围应返回错误")
+    }
+    if _, err := selectVersion(fixtureVersions, versionCriteria{LTSName: "unknown", AllowPrereleaseLTS: true}); err == nil {
+        t.Errorf("无法确定主版本的代号应返回错误")
+    }
+}
+
+func TestMatchVersions(t *testing.T) {
+    got, err := matchVersions(fixtureVersions, versionCriteria{Channel: "lts"}, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []string{"v22.11.0", "v20.18.0", "v20.11.0", "v18.20.4"}
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("matchVersions = %v，期望 %v", got, want)
+    }
+
+    got, err = matchVersions(fixtureVersions, versionCriteria{Channel: "current"}, 3)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := []string{"v23.1.0", "v22.11.0", "v22.10.0"}; !reflect.DeepEqual(got, want) {
+        t.Fatalf("limit 3: %v，期望 %v", got, want)
+    }
+
+    // 缺少发布日期的版本在按日期筛选时不选
+    got, err = matchVersions(fixtureVersions, versionCriteria{Channel: "current", Range: "18.x", Since: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := []string{"v18.20.4"}; !reflect.DeepEqual(got, want) {
+        t.Fatalf("since-date: %v，期望 %v", got, want)
+    }
+}
+
+func TestNormalizeVersion(t *testing.T) {
+    for _, in := range []string{"v20.11.0", "20.11.0", " 20.11.0 "} {
+        got, err := normalizeVersion(in)
+        if err != nil {
+            t.Fatalf("normalizeVersion(%q): %v", in, err)
+        }
+        if got != "v20.11.0" {
+            t.Fatalf("normalizeVersion(%q) = %q，期望 v20.11.0", in, got)
+        }
+    }
+    for _, in := range []string{"", "v", "latest", "20", "20.11", "v20.11.0.1", "vv20.11.0", "20.11.x", "../v20.11.0", "v20.11.0-rc.1"} {
+        if got, err := normalizeVersion(in); err == nil {
+            t.Errorf("normalizeVersion(%q) = %q，期望报错", in, got)
+        }
+    }
+}
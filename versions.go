@@ -0,0 +1,234 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "sort"
+    "sync"
+    "time"
+)
+
+// -versions 汇总清单中的一项
+type versionsEntry struct {
+    Platform     string `json:"platform"`
+    Output       string `json:"output"` // 相对 -out 的路径
+    SHA256       string `json:"sha256"`
+    SourceURL    string `json:"sourceUrl"`
+    SourceSHA256 string `json:"sourceSha256,omitempty"`
+    OriginalName string `json:"originalName,omitempty"`
+}
+
+// -versions all: 版本列表在运行时从 index.json 按选择条件得出
+func versionsAll() bool {
+    return len(opts.Versions) == 1 && opts.Versions[0] == "all"
+}
+
+// 得到 -versions 的实际版本列表: all 时获取 index.json 选出全部满足条件的版本，按从旧到新排列
+func resolveVersions(ctx context.Context) ([]string, error) {
+    if !versionsAll() {
+        return opts.Versions, nil
+    }
+    index, err := loadIndex(ctx)
+    if err != nil {
+        return nil, err
+    }
+    matched, err := matchVersions(index, criteriaFromOptions(), 0)
+    if err != nil {
+        return nil, fmt.Errorf("-versions all: %w", err)
+    }
+    slices.Reverse(matched)
+    logf(levelSummary, "📋 按条件选出 %d 个版本: %v\n", len(matched), matched)
+    return matched, nil
+}
+
+// -versions: 为每个版本构建完整目标矩阵，输出到 -out/<version>/，
+// 结束后在 -out 写出按版本汇总的 versions.json
+// -version-concurrency 控制同时构建的版本数，各阶段并发上限对整个任务生效，不会随版本数成倍增加
+// -run-state 覆盖所有版本，全部成功后才删除，中断后重新运行会跳过任意版本中已完成的目标
+func runVersions(ctx context.Context) (int, error) {
+    baseOut := opts.Out
+    versions, err := resolveVersions(ctx)
+    if err != nil {
+        return exitFailure, err
+    }
+    opts.Versions = versions
+    if err := trainBatchDict(ctx); err != nil {
+        return exitFailure, err
+    }
+    parallel := max(min(opts.VersionConcurrency, len(versions)), 1)
+    selected, err := prepareTargets(parallel)
+    if err != nil {
+        return exitFailure, err
+    }
+    if err := loadRunStateFile(); err != nil {
+        return exitFailure, err
+    }
+    initStageLimits()
+    if opts.Deadline > 0 {
+        deadlineAt = time.Now().Add(opts.Deadline)
+    }
+    if parallel > 1 {
+        logf(levelSummary, "🔀 同时构建 %d 个版本 (每个 %d 个目标)，各阶段上限由所有版本共享: 下载 %d，解压 %d，压缩 %d，同时进行的操作最多 %d 个\n",
+            parallel, len(selected), opts.DownloadConcurrency, opts.ExtractConcurrency, opts.CompressConcurrency,
+            opts.DownloadConcurrency+opts.ExtractConcurrency+opts.CompressConcurrency)
+    }
+    failureCount.Store(0)
+    reportRuns = nil
+    sriResults = nil
+    resetSpeedSamples()
+
+    var (
+        wg       sync.WaitGroup
+        errMu    sync.Mutex
+        firstErr error
+        codes    = make([]int, len(versions))
+        entries  = make([][]versionsEntry, len(versions))
+        sem      = make(chan struct{}, parallel)
+    )
+    stopped := func() bool {
+        errMu.Lock()
+        defer errMu.Unlock()
+        return firstErr != nil
+    }
+    for i, version := range versions {
+        release, err := acquire(ctx, sem)
+        if err != nil {
+            return exitFailure, err
+        }
+        // 等到有空位时再检查，已在进行的版本的失败也计算在内
+        if tooManyFailures() || stopped() {
+            release()
+            if !stopped() {
+                logf(levelError, "\n⛔ 已超过 -max-failures %d，跳过其余 %d 个版本: %v\n",
+                    opts.MaxFailures, len(versions)-i, versions[i:])
+            }
+            for j := i; j < len(versions); j++ {
+                codes[j] = exitFailure
+            }
+            break
+        }
+        wg.Add(1)
+        go func(i int, version string) {
+            defer wg.Done()
+            defer release()
+            code, es, err := buildVersionDir(ctx, baseOut, version, selected)
+            if err != nil {
+                errMu.Lock()
+                if firstErr == nil {
+                    firstErr = err
+                }
+                errMu.Unlock()
+                return
+            }
+            codes[i], entries[i] = code, es
+        }(i, version)
+    }
+    wg.Wait()
+    // 各版本的结果收集齐后一次写出，已完成的版本即使随后出错也会写出
+    if opts.EmitSRI != "" {
+        if err := emitSRI(); err != nil {
+            logf(levelError, "⚠️  写出 -emit-sri 失败: %v\n", err)
+        }
+    }
+    if firstErr != nil {
+        return exitFailure, firstErr
+    }
+
+    manifest := map[string][]versionsEntry{}
+    for i, version := range versions {
+        if entries[i] != nil {
+            manifest[version] = entries[i]
+        }
+    }
+    if err := writeAtomic(filepath.Join(baseOut, "versions.json"), func(part string) error {
+        return writeJSONFile(part, manifest)
+    }); err != nil {
+        return exitFailure, fmt.Errorf("写出 versions.json 失败: %w", err)
+    }
+    if s3Store != nil {
+        if err := s3Store.upload(ctx, filepath.Join(baseOut, "versions.json"), "versions.json", &targetResult{}); err != nil {
+            return exitFailure, err
+        }
+    }
+    code := combineExitCodes(codes)
+    if code == exitOK {
+        if err := runStateFile.finish(); err != nil {
+            logf(levelError, "⚠️  删除 -run-state 文件失败: %v\n", err)
+        }
+    }
+    return code, nil
+}
+
+// 构建 -versions 中的一个版本到 -out/<version>/，返回退出码和该版本在 versions.json 中的条目
+// 只有无法继续整个任务的错误 (建目录、读输出) 通过 err 返回，目标失败体现在退出码中
+func buildVersionDir(ctx context.Context, baseOut, version string, selected map[string]string) (int, []versionsEntry, error) {
+    logf(levelSummary, "\n📦 构建 %s\n", version)
+    out := filepath.Join(baseOut, version)
+    if err := os.MkdirAll(longPath(out), 0o755); err != nil {
+        return exitFailure, nil, err
+    }
+    results, code, err := buildVersion(ctx, version, out, selected)
+    if err != nil {
+        logf(levelError, "❌ %s: %v\n", version, err)
+        code = exitFailure
+    }
+    entries, err := versionsEntries(baseOut, results)
+    if err != nil {
+        return exitFailure, nil, err
+    }
+    return code, entries, nil
+}
+
+func versionsEntries(baseOut string, results []*targetResult) ([]versionsEntry, error) {
+    entries := []versionsEntry{}
+    for _, res := range results {
+        if res.Err != nil {
+            continue
+        }
+        sum := res.OutputSHA256
+        if sum == "" {
+            var err error
+            if sum, err = fileSHA256(res.OutFile); err != nil {
+                return nil, err
+            }
+        }
+        rel, err := filepath.Rel(baseOut, res.OutFile)
+        if err != nil {
+            rel = res.OutFile
+        }
+        entries = append(entries, versionsEntry{
+            Platform:     res.Platform,
+            Output:       filepath.ToSlash(rel),
+            SHA256:       sum,
+            SourceURL:    res.URL,
+            SourceSHA256: res.SourceSHA256,
+            OriginalName: originalName(res.Platform),
+        })
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Platform < entries[j].Platform })
+    return entries, nil
+}
+
+// 合并各版本的退出码: 全部成功为 0，全部失败为 1，其余为部分失败
+func combineExitCodes(codes []int) int {
+    ok, failed := 0, 0
+    for _, c := range codes {
+        switch c {
+        case exitOK:
+            ok++
+        case exitFailure:
+            failed++
+        }
+    }
+    switch {
+    case ok == len(codes):
+        return exitOK
+    case failed == len(codes):
+        return exitFailure
+    default:
+        return exitPartial
+    }
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+    "archive/zip"
+    "bufio"
+    "bytes"
+    "compress/flate"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "strings"
+)
+
+const (
+    zipLocalHeaderSig    = 0x04034b50
+    zipDataDescriptorSig = 0x08074b50
+    zipLocalHeaderLen    = 30
+    zipFlagDescriptor    = 0x8
+)
+
+// -zip-recover: 中央目录损坏、zip.NewReader 失败时，从头扫描本地文件头找到 node.exe 直接解压
+// 只是尽力而为: 依赖本地文件头和数据本身完好，解压后按 CRC-32 核对，不符即失败
+func (o ExtractOptions) recoverNodeFromZip(ra io.ReaderAt, size int64, w io.Writer) error {
+    sr := io.NewSectionReader(ra, 0, size)
+    br := bufio.NewReaderSize(sr, 1<<16)
+    sig := []byte{0x50, 0x4b, 0x03, 0x04}
+    var off int64
+    for {
+        // 逐字节查找 PK\x03\x04，br 的位置即 off
+        b, err := br.ReadByte()
+        if err == io.EOF {
+            return errNoNodeExe
+        }
+        if err != nil {
+            return err
+        }
+        off++
+        if b != sig[0] {
+            continue
+        }
+        peek, err := br.Peek(3)
+        if err != nil || !bytes.Equal(peek, sig[1:]) {
+            continue
+        }
+        start := off - 1
+        found, err := o.recoverLocalFile(ra, size, start, w)
+        if err != nil {
+            return err
+        }
+        if found {
+            return nil
+        }
+    }
+}
+
+// 解析 start 处的本地文件头，是 node.exe 时解压写入 w 并返回 true
+func (o ExtractOptions) recoverLocalFile(ra io.ReaderAt, size, start int64, w io.Writer) (bool, error) {
+    hdr := make([]byte, zipLocalHeaderLen)
+    if _, err := ra.ReadAt(hdr, start); err != nil {
+        return false, nil
+    }
+    le := binary.LittleEndian
+    flags := le.Uint16(hdr[6:])
+    method := le.Uint16(hdr[8:])
+    crc := le.Uint32(hdr[14:])
+    csize := int64(le.Uint32(hdr[18:]))
+    nameLen := int64(le.Uint16(hdr[26:]))
+    extraLen := int64(le.Uint16(hdr[28:]))
+    name := make([]byte, nameLen)
+    if _, err := ra.ReadAt(name, start+zipLocalHeaderLen); err != nil {
+        return false, nil
+    }
+    if !strings.HasSuffix(string(name), "node.exe") {
+        return false, nil
+    }
+    if err := checkMember(string(name), ""); err != nil {
+        return false, err
+    }
+
+    dataStart := start + zipLocalHeaderLen + nameLen + extraLen
+    descriptor := flags&zipFlagDescriptor != 0
+    if descriptor || csize == 0xFFFFFFFF {
+        // 大小写在数据之后 (或在 zip64 扩展字段中)，只有 deflate 能自行判断结尾
+        csize = size - dataStart
+    }
+    data := io.NewSectionReader(ra, dataStart, csize)
+    // flate 对 io.ByteReader 不会多读，解压结束后 src 正好停在数据描述符处
+    src := bufio.NewReader(data)
+    var r io.Reader
+    switch method {
+    case zip.Store:
+        if descriptor {
+            return false, errors.New("node.exe 以存储方式保存且大小记录在数据之后，无法恢复")
+        }
+        r = src
+    case zip.Deflate:
+        fr := flate.NewReader(src)
+        defer fr.Close()
+        r = fr
+    default:
+        return false, fmt.Errorf("node.exe 使用了不支持的压缩方式 %d", method)
+    }
+
+    mr, err := o.limitMember(string(name), -1, r)
+    if err != nil {
+        return false, err
+    }
+    h := crc32.NewIEEE()
+    if _, err := io.Copy(io.MultiWriter(w, h), mr); err != nil {
+        return false, fmt.Errorf("恢复 node.exe 失败: %w", err)
+    }
+    if descriptor {
+        // 数据描述符: [签名] CRC-32 压缩大小 解压大小
+        d := make([]byte, 8)
+        if _, err := io.ReadFull(src, d); err != nil {
+            return false, fmt.Errorf("读取数据描述符失败: %w", err)
+        }
+        if le.Uint32(d) == zipDataDescriptorSig {
+            crc = le.Uint32(d[4:])
+        } else {
+            crc = le.Uint32(d)
+        }
+    }
+    if h.Sum32() != crc {
+        return false, fmt.Errorf("恢复出的 node.exe CRC-32 不符 (期望 %08x，实际 %08x)", crc, h.Sum32())
+    }
+    return true, nil
+}
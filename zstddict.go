@@ -0,0 +1,146 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/klauspost/compress/dict"
+    "github.com/klauspost/compress/zstd"
+)
+
+// -zstd-dict 的字典内容，为 nil 时不使用字典
+var zstdDict []byte
+
+const (
+    dictSamples     = 4         // 训练时抽样的可执行文件数
+    dictSampleBytes = 32 << 20  // 每个样本最多取开头这么多字节，限制训练内存
+    dictSampleChunk = 128 << 10 // 样本切成小块交给训练器
+    dictMaxSize     = 110 << 10 // 与 zstd 命令行的默认字典大小一致
+)
+
+// 读取已有的 -zstd-dict；文件不存在时只有 -versions 批量模式会在开始前训练
+func loadZstdDict() error {
+    if opts.ZstdDict == "" {
+        return nil
+    }
+    if opts.Format != "zstd" {
+        return fmt.Errorf("-zstd-dict 只能用于 -format zstd")
+    }
+    data, err := os.ReadFile(opts.ZstdDict)
+    if errors.Is(err, os.ErrNotExist) && len(opts.Versions) > 0 {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("读取 -zstd-dict 失败 (只有 -versions 批量模式会训练新字典): %w", err)
+    }
+    zstdDict = data
+    return nil
+}
+
+// 批量构建开始前训练一次字典并写到 -zstd-dict，之后所有版本、所有平台的输出共用
+// 从不同版本、不同平台各抽一个压缩包，取解压出的可执行文件开头部分作为样本
+func trainBatchDict(ctx context.Context) error {
+    if opts.ZstdDict == "" || zstdDict != nil {
+        return nil
+    }
+    selected, err := selectTargets()
+    if err != nil {
+        return err
+    }
+    platforms := make([]string, 0, len(selected))
+    for _, p := range selected {
+        platforms = append(platforms, p)
+    }
+    sort.Strings(platforms)
+
+    var inputs [][]byte
+    samples := 0
+    for i, version := range opts.Versions {
+        if samples == dictSamples {
+            break
+        }
+        platform := platforms[i%len(platforms)]
+        data, err := dictSample(ctx, version, platform, i)
+        if err != nil {
+            logf(levelError, "⚠️  字典样本 %s %s 获取失败: %v\n", version, platform, err)
+            continue
+        }
+        samples++
+        for len(data) > 0 {
+            n := min(len(data), dictSampleChunk)
+            inputs = append(inputs, data[:n])
+            data = data[n:]
+        }
+    }
+    if samples == 0 {
+        return fmt.Errorf("没有可用的字典样本")
+    }
+
+    _, level := zstd.EncoderLevelFromString(opts.ZstdLevel)
+    d, err := dict.BuildZstdDict(inputs, dict.Options{
+        MaxDictSize: dictMaxSize,
+        HashBytes:   6,
+        ZstdLevel:   level,
+    })
+    if err != nil {
+        return fmt.Errorf("训练 zstd 字典失败: %w", err)
+    }
+    if err := writeAtomic(opts.ZstdDict, func(part string) error {
+        return os.WriteFile(part, d, 0o644)
+    }); err != nil {
+        return err
+    }
+    if err := chownOutput(opts.ZstdDict); err != nil {
+        return err
+    }
+    zstdDict = d
+    logf(levelSummary, "📚 已用 %d 个样本训练 zstd 字典: %s (%s)\n", samples, opts.ZstdDict, formatBytes(int64(len(d))))
+    return nil
+}
+
+// 获取一个压缩包并解压出 node 可执行文件的开头部分
+func dictSample(ctx context.Context, version, platform string, i int) ([]byte, error) {
+    path := filepath.Join(opts.SourceDir, archiveName(version, platform))
+    if opts.SourceDir == "" {
+        path = tempPath(filepath.Join(opts.Out, fmt.Sprintf("zstd-dict-sample-%d", i)), version, ".tmp")
+        defer os.Remove(path)
+        if _, err := downloadArchive(ctx, path, buildURL(version, platform), platform); err != nil {
+            return nil, err
+        }
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    buf := &prefixBuffer{max: dictSampleBytes}
+    if strings.HasPrefix(platform, "win") {
+        info, err := f.Stat()
+        if err != nil {
+            return nil, err
+        }
+        err = ExtractNodeZip(f, info.Size(), buf, extractOptions())
+    } else {
+        err = ExtractNode(f, archiveFormat(platform), buf, extractOptions())
+    }
+    return buf.data, err
+}
+
+// 只保留前 max 字节，其余丢弃但照常返回成功，让解压流程正常走完
+type prefixBuffer struct {
+    data []byte
+    max  int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+    if room := b.max - len(b.data); room > 0 {
+        b.data = append(b.data, p[:min(room, len(p))]...)
+    }
+    return len(p), nil
+}